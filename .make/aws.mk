@@ -139,10 +139,11 @@ env-key-location: ## Returns the environment encryption key location
 
 .PHONY: encrypt
 encrypt: ## Encrypts data using a KMY Key ID (awscli v2)
-	@# Example make encrypt kms_key_id=b329... encrypt_value=YourSecret
+	@# Example make encrypt kms_key_id=b329... encrypt_value=YourSecret encryption_context=LambdaFunctionName=your-function
 	@test $(kms_key_id)
 	@test "$(encrypt_value)"
-	@aws kms encrypt --output text --query CiphertextBlob --key-id $(kms_key_id) --plaintext "$(shell echo "$(encrypt_value)" | base64)"
+	@aws kms encrypt --output text --query CiphertextBlob --key-id $(kms_key_id) --plaintext "$(shell echo "$(encrypt_value)" | base64)" \
+		$(if $(encryption_context),--encryption-context $(encryption_context),)
 
 .PHONY: invalidate-cache
 invalidate-cache: ## Invalidates a cloudfront cache based on path
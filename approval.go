@@ -0,0 +1,444 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/codepipeline"
+	"github.com/aws/aws-sdk-go/service/codepipeline/codepipelineiface"
+)
+
+// Approval defaults
+const (
+	actionCategoryApproval   = "Approval"
+	approvalCommandApprove   = "/approve"
+	approvalCommandReject    = "/reject"
+	approvalContext          = githubContext + "/approval"
+	eventKindApproval        = "approval"
+	githubEventHeader        = "X-GitHub-Event"
+	githubEventIssueComment  = "issue_comment"
+	githubEventReview        = "pull_request_review"
+	githubSignatureHeader    = "X-Hub-Signature-256"
+	githubWebhookPath        = "/github"
+	maxApprovalSummaryLength = 512
+)
+
+// errNoPendingApproval is returned when no pipeline is waiting for an approval of the commit
+var errNoPendingApproval = errors.New("no pending approval for the commit")
+
+// actionType is the type of the action in an action execution state change event
+type actionType struct {
+	Category string `json:"category"`
+	Owner    string `json:"owner"`
+	Provider string `json:"provider"`
+	Version  string `json:"version"`
+}
+
+// approvalHandler is the handler of the action events of manual approval actions
+var approvalHandler = &eventHandler{kind: eventKindApproval, process: processApprovalEvent, validate: validateActionEvent}
+
+// isApprovalEvent will return true if the event is an action event of a manual approval action
+func isApprovalEvent(ev event) bool {
+	return ev.DetailType == detailTypeActionStateChange && ev.Detail != nil &&
+		ev.Detail.Type != nil && ev.Detail.Type.Category == actionCategoryApproval
+}
+
+// processApprovalEvent will post the approval status (awaiting approval, approved or rejected) for every source revision
+func processApprovalEvent(ev event) error {
+
+	// Get the custom message and review url of the approval (only shown while waiting)
+	var customData, reviewURL string
+	if ev.Detail.State == "STARTED" {
+		var err error
+		if customData, reviewURL, err = getApprovalConfiguration(
+			ev.Detail.Pipeline, ev.Detail.Stage, ev.Detail.Action, codepipeline.New(getEventSession(ev)),
+		); err != nil {
+			return err
+		}
+	}
+
+	// Link to the approval (pipeline view)
+	approvalLink := fmt.Sprintf(
+		"https://%s.console.aws.amazon.com/codesuite/codepipeline/pipelines/%s/view?region=%s",
+		getRegion(ev), ev.Detail.Pipeline, getRegion(ev))
+
+	return processExecutionEvent(ev, func(update *statusUpdate) {
+		update.Context = approvalContext
+		update.Description = getApprovalDescription(ev.Detail.State, customData, reviewURL)
+		update.State = getEventStatus(ev.Detail.State)
+		update.TargetURL = approvalLink
+	})
+}
+
+// getApprovalConfiguration will return the custom message (CustomData) and review url (ExternalEntityLink) of the approval action
+func getApprovalConfiguration(pipelineName, stageName, actionName string,
+	pipeline codepipelineiface.CodePipelineAPI,
+) (customData, reviewURL string, err error) {
+	var output *codepipeline.GetPipelineOutput
+	if output, err = pipeline.GetPipeline(&codepipeline.GetPipelineInput{Name: aws.String(pipelineName)}); err != nil {
+		return
+	} else if output == nil || output.Pipeline == nil {
+		return
+	}
+	for _, stage := range output.Pipeline.Stages {
+		if aws.StringValue(stage.Name) != stageName {
+			continue
+		}
+		for _, action := range stage.Actions {
+			if aws.StringValue(action.Name) == actionName {
+				customData = aws.StringValue(action.Configuration["CustomData"])
+				reviewURL = aws.StringValue(action.Configuration["ExternalEntityLink"])
+				return
+			}
+		}
+	}
+	return
+}
+
+// getApprovalDescription will return the description of the approval status (IE: Awaiting approval: Deploy to production?)
+func getApprovalDescription(state, customData, reviewURL string) string {
+	var description string
+	switch state {
+	case "STARTED":
+		description = "Awaiting approval"
+		if len(customData) > 0 {
+			description += ": " + customData
+		}
+		if len(reviewURL) > 0 {
+			description += " (review: " + reviewURL + ")"
+		}
+	case "SUCCEEDED":
+		description = "Approved"
+	case "FAILED":
+		description = "Rejected"
+	default:
+		return getEventDescription("Approval", state)
+	}
+	if len(description) > 140 {
+		description = description[:137] + "..."
+	}
+	return description
+}
+
+// approvalRequest is an approval (or rejection) from a GitHub review or comment
+type approvalRequest struct {
+	Approve     bool
+	Login       string
+	Owner       string
+	PullRequest int
+	Repository  string
+	SHA         string
+	URL         string
+}
+
+// githubWebhookEvent is the part of a GitHub pull_request_review or issue_comment webhook that is used
+type githubWebhookEvent struct {
+	Action  string `json:"action"`
+	Comment *struct {
+		Body    string     `json:"body"`
+		HTMLURL string     `json:"html_url"`
+		User    githubUser `json:"user"`
+	} `json:"comment"`
+	Issue *struct {
+		Number      int             `json:"number"`
+		PullRequest json.RawMessage `json:"pull_request"`
+	} `json:"issue"`
+	PullRequest *githubPullRequest `json:"pull_request"`
+	Repository  struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+	Review *struct {
+		HTMLURL string     `json:"html_url"`
+		State   string     `json:"state"`
+		User    githubUser `json:"user"`
+	} `json:"review"`
+}
+
+// githubUser is a GitHub user (webhooks)
+type githubUser struct {
+	Login string `json:"login"`
+}
+
+// githubPullRequest is a GitHub pull request (webhooks and the pulls api)
+type githubPullRequest struct {
+	Head struct {
+		SHA string `json:"sha"`
+	} `json:"head"`
+	Number int `json:"number"`
+}
+
+// githubWebhookHandler will approve or reject the pending manual approval of a pull request (GitHub webhook)
+// Approving reviews and /approve comments approve, reviews requesting changes and /reject comments reject
+func githubWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, &httpResponse{Error: "method not allowed"})
+		return
+	}
+
+	// Load the configuration (webhook secret and approvers)
+	if err := getConfiguration(); err != nil {
+		log.Printf("unable to load the configuration: %s", err.Error())
+		writeJSON(w, http.StatusInternalServerError, &httpResponse{Error: "invalid configuration"})
+		return
+	}
+	secret := getConfig().GithubWebhookSecret
+	if len(secret) == 0 {
+		writeJSON(w, http.StatusNotFound, &httpResponse{Error: "webhook is not configured"})
+		return
+	}
+
+	// Read and verify the body (signed with the webhook secret)
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxHTTPBodySize))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, &httpResponse{Error: err.Error()})
+		return
+	} else if !isValidSignature(body, r.Header.Get(githubSignatureHeader), secret) {
+		writeJSON(w, http.StatusUnauthorized, &httpResponse{Error: "invalid signature"})
+		return
+	}
+
+	// Get the approval from the review or comment
+	var request *approvalRequest
+	if request, err = getApprovalRequest(r.Header.Get(githubEventHeader), body); err != nil {
+		writeJSON(w, http.StatusBadRequest, &httpResponse{Error: err.Error()})
+		return
+	} else if request == nil {
+		writeJSON(w, http.StatusOK, &httpResponse{Status: "ignored"})
+		return
+	} else if !isApprover(request.Login) {
+		log.Printf("ignoring approval from: %s (not in GITHUB_APPROVERS)", request.Login)
+		writeJSON(w, http.StatusForbidden, &httpResponse{Error: "not an approver"})
+		return
+	}
+
+	// Comments have no commit, use the head of the pull request
+	if len(request.SHA) == 0 {
+		var pullRequest githubPullRequest
+		if err = githubRequest(withOwnerCredentials(r.Context(), request.Owner), http.MethodGet, fmt.Sprintf(
+			"/repos/%s/%s/pulls/%d", request.Owner, request.Repository, request.PullRequest,
+		), nil, &pullRequest, http.StatusOK); err != nil {
+			writeJSON(w, http.StatusBadGateway, &httpResponse{Error: err.Error()})
+			return
+		}
+		request.SHA = pullRequest.Head.SHA
+	}
+
+	// Approve or reject the pending approvals of the commit
+	if err = putApprovalResults(request, codepipeline.New(awsSession)); errors.Is(err, errNoPendingApproval) {
+		writeJSON(w, http.StatusNotFound, &httpResponse{Error: err.Error()})
+		return
+	} else if err != nil {
+		writeJSON(w, http.StatusBadGateway, &httpResponse{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, &httpResponse{Status: "ok"})
+}
+
+// isValidSignature will check the X-Hub-Signature-256 header (HMAC SHA-256 of the body)
+func isValidSignature(body []byte, signature, secret string) bool {
+	if !strings.HasPrefix(signature, "sha256=") {
+		return false
+	}
+	expected, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// isApprover will return true if the GitHub user is allowed to approve (GITHUB_APPROVERS)
+func isApprover(login string) bool {
+	for _, approver := range getConfig().GithubApprovers {
+		if len(login) > 0 && strings.EqualFold(strings.TrimSpace(approver), login) {
+			return true
+		}
+	}
+	return false
+}
+
+// getApprovalRequest will return the approval from a submitted review or a new pull request comment
+// Returns nil if the webhook is not an approval (other events, actions, review states or comments)
+func getApprovalRequest(eventName string, body []byte) (request *approvalRequest, err error) {
+	if eventName != githubEventReview && eventName != githubEventIssueComment {
+		return
+	}
+	var webhook githubWebhookEvent
+	if err = json.Unmarshal(body, &webhook); err != nil {
+		return
+	}
+	request = &approvalRequest{}
+	if request.Owner, request.Repository, err = parseRepository(webhook.Repository.FullName); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case eventName == githubEventReview && webhook.Action == "submitted" &&
+		webhook.Review != nil && webhook.PullRequest != nil:
+		switch strings.ToLower(webhook.Review.State) {
+		case "approved":
+			request.Approve = true
+		case "changes_requested":
+		default:
+			return nil, nil
+		}
+		request.Login = webhook.Review.User.Login
+		request.PullRequest = webhook.PullRequest.Number
+		request.SHA = webhook.PullRequest.Head.SHA
+		request.URL = webhook.Review.HTMLURL
+	case eventName == githubEventIssueComment && webhook.Action == "created" &&
+		webhook.Comment != nil && webhook.Issue != nil && len(webhook.Issue.PullRequest) > 0:
+		command := strings.Fields(webhook.Comment.Body)
+		if len(command) == 0 {
+			return nil, nil
+		}
+		switch command[0] {
+		case approvalCommandApprove:
+			request.Approve = true
+		case approvalCommandReject:
+		default:
+			return nil, nil
+		}
+		request.Login = webhook.Comment.User.Login
+		request.PullRequest = webhook.Issue.Number
+		request.URL = webhook.Comment.HTMLURL
+	default:
+		return nil, nil
+	}
+	return
+}
+
+// putApprovalResults will approve or reject every pending approval of the commit (APPROVAL_PIPELINES)
+// The approval token is taken from the pipeline state, the commit from the execution of the stage
+func putApprovalResults(request *approvalRequest, pipeline codepipelineiface.CodePipelineAPI) (err error) {
+
+	// Get the pipeline(s)
+	var pipelineNames []string
+	for _, name := range getConfig().ApprovalPipelines {
+		var names []string
+		if names, err = getPipelineNames(name, pipeline); err != nil {
+			return
+		}
+		pipelineNames = append(pipelineNames, names...)
+	}
+
+	// Set the result
+	result := &codepipeline.ApprovalResult{
+		Status:  aws.String(codepipeline.ApprovalStatusRejected),
+		Summary: aws.String(fmt.Sprintf("Rejected by %s on GitHub: %s", request.Login, request.URL)),
+	}
+	if request.Approve {
+		result.Status = aws.String(codepipeline.ApprovalStatusApproved)
+		result.Summary = aws.String(fmt.Sprintf("Approved by %s on GitHub: %s", request.Login, request.URL))
+	}
+	if len(aws.StringValue(result.Summary)) > maxApprovalSummaryLength {
+		result.Summary = aws.String(aws.StringValue(result.Summary)[:maxApprovalSummaryLength])
+	}
+
+	// Find the pending approvals of the commit (collect the errors per pipeline)
+	var errs []error
+	var approvals int
+	for _, pipelineName := range pipelineNames {
+		var state *codepipeline.GetPipelineStateOutput
+		if state, err = pipeline.GetPipelineState(&codepipeline.GetPipelineStateInput{
+			Name: aws.String(pipelineName),
+		}); err != nil {
+			errs = append(errs, fmt.Errorf("pipeline %s: %w", pipelineName, err))
+			continue
+		}
+		for _, stage := range state.StageStates {
+			if stage.LatestExecution == nil ||
+				aws.StringValue(stage.LatestExecution.Status) != codepipeline.StageExecutionStatusInProgress {
+				continue
+			}
+			for _, action := range stage.ActionStates {
+
+				// Only approval actions have a token
+				if action.LatestExecution == nil || len(aws.StringValue(action.LatestExecution.Token)) == 0 ||
+					aws.StringValue(action.LatestExecution.Status) != codepipeline.ActionExecutionStatusInProgress {
+					continue
+				}
+
+				// Check the commit of the execution
+				var ok bool
+				executionID := aws.StringValue(stage.LatestExecution.PipelineExecutionId)
+				if ok, err = hasRevision(pipelineName, executionID, request, pipeline); err != nil {
+					errs = append(errs, fmt.Errorf("pipeline %s execution %s: %w", pipelineName, executionID, err))
+					continue
+				} else if !ok {
+					continue
+				}
+
+				log.Printf("%s pipeline: %s stage: %s action: %s for commit: %s by: %s",
+					aws.StringValue(result.Status), pipelineName, aws.StringValue(stage.StageName),
+					aws.StringValue(action.ActionName), request.SHA, request.Login)
+				if getConfig().DryRun { // Logged only
+					approvals++
+					continue
+				}
+				if _, err = pipeline.PutApprovalResult(&codepipeline.PutApprovalResultInput{
+					ActionName:   action.ActionName,
+					PipelineName: aws.String(pipelineName),
+					Result:       result,
+					StageName:    stage.StageName,
+					Token:        action.LatestExecution.Token,
+				}); err != nil {
+					errs = append(errs, fmt.Errorf("pipeline %s action %s: %w", pipelineName, aws.StringValue(action.ActionName), err))
+					continue
+				}
+				approvals++
+			}
+		}
+	}
+
+	if err = errors.Join(errs...); err == nil && approvals == 0 {
+		err = errNoPendingApproval
+	}
+	return
+}
+
+// hasRevision will return true if the commit of the request is a source revision of the execution
+// The revision must be from the repository of the pull request (the same commit can be in a fork or a mirror)
+func hasRevision(pipelineName, executionID string, request *approvalRequest,
+	pipeline codepipelineiface.CodePipelineAPI,
+) (bool, error) {
+	executionOutput, err := getExecutionOutput(pipelineName, executionID, pipeline)
+	if err != nil {
+		return false, err
+	}
+	for _, artifact := range executionOutput.PipelineExecution.ArtifactRevisions {
+		if aws.StringValue(artifact.RevisionId) != request.SHA {
+			continue
+		}
+
+		// Same commit from the repository of the pull request (not a fork or a mirror)
+		revisionURL, parseErr := url.Parse(aws.StringValue(artifact.RevisionUrl))
+		if parseErr != nil || !isGitHubURL(revisionURL) {
+			continue
+		}
+		if owner, repo, repoErr := getGitHubRepository(revisionURL); repoErr == nil &&
+			strings.EqualFold(owner, request.Owner) && strings.EqualFold(repo, request.Repository) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// getHTTPHandler will return the handler of the path (GitHub webhooks or status refreshes)
+func getHTTPHandler(path string) http.HandlerFunc {
+	if strings.TrimSuffix(path, "/") == githubWebhookPath {
+		return githubWebhookHandler
+	}
+	return statusHandler
+}
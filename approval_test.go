@@ -0,0 +1,328 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/codepipeline"
+)
+
+// GetPipelineState is a mock request for codepipeline (a pending approval for the "approval" pipeline)
+func (m *mockCodePipelineClient) GetPipelineState(input *codepipeline.GetPipelineStateInput) (*codepipeline.GetPipelineStateOutput, error) {
+	if aws.StringValue(input.Name) != "approval" {
+		return &codepipeline.GetPipelineStateOutput{PipelineName: input.Name}, nil
+	}
+	return &codepipeline.GetPipelineStateOutput{
+		PipelineName: input.Name,
+		StageStates: []*codepipeline.StageState{{
+			ActionStates: []*codepipeline.ActionState{{
+				ActionName:      aws.String("Source"),
+				LatestExecution: &codepipeline.ActionExecution{Status: aws.String(codepipeline.ActionExecutionStatusSucceeded)},
+			}},
+			LatestExecution: &codepipeline.StageExecution{
+				PipelineExecutionId: aws.String("12345"),
+				Status:              aws.String(codepipeline.StageExecutionStatusSucceeded),
+			},
+			StageName: aws.String("Source"),
+		}, {
+			ActionStates: []*codepipeline.ActionState{{
+				ActionName: aws.String("Manual"),
+				LatestExecution: &codepipeline.ActionExecution{
+					Status: aws.String(codepipeline.ActionExecutionStatusInProgress),
+					Token:  aws.String("approval-token"),
+				},
+			}},
+			LatestExecution: &codepipeline.StageExecution{
+				PipelineExecutionId: aws.String("12345"),
+				Status:              aws.String(codepipeline.StageExecutionStatusInProgress),
+			},
+			StageName: aws.String("Approve"),
+		}},
+	}, nil
+}
+
+// PutApprovalResult is a mock request for codepipeline (records the result)
+func (m *mockCodePipelineClient) PutApprovalResult(input *codepipeline.PutApprovalResultInput) (*codepipeline.PutApprovalResultOutput, error) {
+	if len(aws.StringValue(input.Token)) == 0 {
+		return nil, errors.New("aws will reject: missing token")
+	}
+	m.approvals = append(m.approvals, input)
+	return &codepipeline.PutApprovalResultOutput{}, nil
+}
+
+// signBody will return the X-Hub-Signature-256 header of the body
+func signBody(body, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// TestGetEventHandler will test getEventHandler() and isApprovalEvent()
+func TestGetEventHandler(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		name         string
+		ev           event
+		expectedKind string
+	}{
+		{"pipeline", event{DetailType: detailTypePipelineStateChange, Detail: &detail{}}, eventKindPipeline},
+		{"action", event{DetailType: detailTypeActionStateChange, Detail: &detail{Type: &actionType{Category: "Build"}}}, eventKindAction},
+		{"approval", event{DetailType: detailTypeActionStateChange, Detail: &detail{Type: &actionType{Category: actionCategoryApproval}}}, eventKindApproval},
+		{"approval category on a stage", event{DetailType: detailTypeStageStateChange, Detail: &detail{Type: &actionType{Category: actionCategoryApproval}}}, eventKindStage},
+	}
+
+	for _, test := range tests {
+		if handler, ok := getEventHandler(test.ev); !ok || handler.kind != test.expectedKind {
+			t.Errorf("%s Failed: [%s] expected kind [%s] got [%v]", t.Name(), test.name, test.expectedKind, handler)
+		}
+	}
+}
+
+// TestGetApprovalConfiguration will test getApprovalConfiguration()
+func TestGetApprovalConfiguration(t *testing.T) {
+	t.Parallel()
+
+	mockPipeline := &mockCodePipelineClient{}
+
+	customData, reviewURL, err := getApprovalConfiguration("approval", "Approve", "Manual", mockPipeline)
+	if err != nil {
+		t.Fatal("error occurred", err.Error())
+	} else if customData != "Deploy to production?" || reviewURL != "https://staging.example.com" {
+		t.Fatal("approval configuration was not as expected", customData, reviewURL)
+	}
+
+	if customData, reviewURL, err = getApprovalConfiguration("approval", "Approve", "Missing", mockPipeline); err != nil {
+		t.Fatal("error occurred", err.Error())
+	} else if len(customData) > 0 || len(reviewURL) > 0 {
+		t.Fatal("expected no configuration for a missing action", customData, reviewURL)
+	}
+
+	if _, _, err = getApprovalConfiguration("", "Approve", "Manual", mockPipeline); err == nil {
+		t.Fatal("error should have occurred")
+	}
+}
+
+// TestGetApprovalDescription will test getApprovalDescription()
+func TestGetApprovalDescription(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		state               string
+		customData          string
+		reviewURL           string
+		expectedDescription string
+	}{
+		{"STARTED", "", "", "Awaiting approval"},
+		{"STARTED", "Deploy to production?", "", "Awaiting approval: Deploy to production?"},
+		{"STARTED", "Deploy?", "https://staging.example.com", "Awaiting approval: Deploy? (review: https://staging.example.com)"},
+		{"SUCCEEDED", "Deploy?", "", "Approved"},
+		{"FAILED", "", "", "Rejected"},
+		{"ABANDONED", "", "", "Approval abandoned"},
+	}
+
+	for _, test := range tests {
+		if description := getApprovalDescription(test.state, test.customData, test.reviewURL); description != test.expectedDescription {
+			t.Errorf("%s Failed: [%s] expected [%s] got [%s]", t.Name(), test.state, test.expectedDescription, description)
+		}
+	}
+
+	if description := getApprovalDescription("STARTED", strings.Repeat("a", 200), ""); len(description) != 140 {
+		t.Fatal("expected the description to be truncated", len(description))
+	}
+}
+
+// TestIsValidSignature will test isValidSignature()
+func TestIsValidSignature(t *testing.T) {
+	t.Parallel()
+
+	body := `{"action":"submitted"}`
+
+	var tests = []struct {
+		name      string
+		signature string
+		expected  bool
+	}{
+		{"valid", signBody(body, "webhook-secret"), true},
+		{"wrong secret", signBody(body, "other-secret"), false},
+		{"missing", "", false},
+		{"sha1", "sha1=" + strings.TrimPrefix(signBody(body, "webhook-secret"), "sha256="), false},
+		{"not hex", "sha256=zz", false},
+	}
+
+	for _, test := range tests {
+		if valid := isValidSignature([]byte(body), test.signature, "webhook-secret"); valid != test.expected {
+			t.Errorf("%s Failed: [%s] expected [%t] got [%t]", t.Name(), test.name, test.expected, valid)
+		}
+	}
+}
+
+// TestGetApprovalRequest will test getApprovalRequest()
+func TestGetApprovalRequest(t *testing.T) {
+	t.Parallel()
+
+	const repository = `"repository":{"full_name":"mrz1836/codepipeline-to-github"}`
+	const pullRequest = `"pull_request":{"number":42,"head":{"sha":"25c0c3e61c4db2c2cde8b163b3ad096875c1ce08"}}`
+
+	var tests = []struct {
+		name            string
+		eventName       string
+		body            string
+		expectedRequest bool
+		expectedApprove bool
+		expectedSHA     string
+		expectedError   bool
+	}{
+		{"approving review", githubEventReview, `{"action":"submitted","review":{"state":"APPROVED","user":{"login":"jane"}},` + pullRequest + `,` + repository + `}`, true, true, "25c0c3e61c4db2c2cde8b163b3ad096875c1ce08", false},
+		{"changes requested", githubEventReview, `{"action":"submitted","review":{"state":"changes_requested","user":{"login":"jane"}},` + pullRequest + `,` + repository + `}`, true, false, "25c0c3e61c4db2c2cde8b163b3ad096875c1ce08", false},
+		{"review comment", githubEventReview, `{"action":"submitted","review":{"state":"commented","user":{"login":"jane"}},` + pullRequest + `,` + repository + `}`, false, false, "", false},
+		{"edited review", githubEventReview, `{"action":"edited","review":{"state":"approved","user":{"login":"jane"}},` + pullRequest + `,` + repository + `}`, false, false, "", false},
+		{"approve comment", githubEventIssueComment, `{"action":"created","comment":{"body":"/approve","user":{"login":"jane"}},"issue":{"number":42,"pull_request":{}},` + repository + `}`, true, true, "", false},
+		{"reject comment", githubEventIssueComment, `{"action":"created","comment":{"body":"/reject  broken on staging","user":{"login":"jane"}},"issue":{"number":42,"pull_request":{}},` + repository + `}`, true, false, "", false},
+		{"other comment", githubEventIssueComment, `{"action":"created","comment":{"body":"looks good, /approve later","user":{"login":"jane"}},"issue":{"number":42,"pull_request":{}},` + repository + `}`, false, false, "", false},
+		{"issue comment", githubEventIssueComment, `{"action":"created","comment":{"body":"/approve","user":{"login":"jane"}},"issue":{"number":42},` + repository + `}`, false, false, "", false},
+		{"ping", "ping", `{"zen":"Keep it logically awesome."}`, false, false, "", false},
+		{"invalid json", githubEventReview, `not json`, false, false, "", true},
+		{"invalid repository", githubEventReview, `{"action":"submitted","repository":{"full_name":""}}`, false, false, "", true},
+	}
+
+	for _, test := range tests {
+		request, err := getApprovalRequest(test.eventName, []byte(test.body))
+		if err == nil && test.expectedError {
+			t.Errorf("%s Failed: [%s] expected to throw an error, but no error", t.Name(), test.name)
+		} else if err != nil && !test.expectedError {
+			t.Errorf("%s Failed: [%s] error occurred [%s]", t.Name(), test.name, err.Error())
+		} else if (request != nil) != test.expectedRequest {
+			t.Errorf("%s Failed: [%s] expected request [%t] got [%v]", t.Name(), test.name, test.expectedRequest, request)
+		} else if request != nil && (request.Approve != test.expectedApprove || request.SHA != test.expectedSHA ||
+			request.Login != "jane" || request.PullRequest != 42 || request.Repository != "codepipeline-to-github") {
+			t.Errorf("%s Failed: [%s] request was not as expected [%+v]", t.Name(), test.name, request)
+		}
+	}
+}
+
+// TestPutApprovalResults will test putApprovalResults()
+func TestPutApprovalResults(t *testing.T) {
+
+	setConfig(t, func(c *loadedConfiguration) {
+		c.ApprovalPipelines = []string{"approval", "other-pipeline"}
+	})
+
+	t.Run("approve the pending approval of the commit", func(t *testing.T) {
+		mockPipeline := &mockCodePipelineClient{}
+		err := putApprovalResults(&approvalRequest{
+			Approve:    true,
+			Login:      "jane",
+			Owner:      "MrZ1836",
+			Repository: "codepipeline-to-github",
+			SHA:        "25c0c3e61c4db2c2cde8b163b3ad096875c1ce08",
+			URL:        "https://github.com/mrz1836/codepipeline-to-github/pull/42#pullrequestreview-1",
+		}, mockPipeline)
+		if err != nil {
+			t.Fatal("error occurred", err.Error())
+		} else if len(mockPipeline.approvals) != 1 {
+			t.Fatal("expected a single approval result", len(mockPipeline.approvals))
+		}
+		approval := mockPipeline.approvals[0]
+		if aws.StringValue(approval.Token) != "approval-token" || aws.StringValue(approval.StageName) != "Approve" ||
+			aws.StringValue(approval.ActionName) != "Manual" ||
+			aws.StringValue(approval.Result.Status) != codepipeline.ApprovalStatusApproved ||
+			!strings.HasPrefix(aws.StringValue(approval.Result.Summary), "Approved by jane on GitHub") {
+			t.Fatal("approval result was not as expected", approval)
+		}
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		mockPipeline := &mockCodePipelineClient{}
+		if err := putApprovalResults(&approvalRequest{
+			Login:      "jane",
+			Owner:      "mrz1836",
+			Repository: "codepipeline-to-github",
+			SHA:        "25c0c3e61c4db2c2cde8b163b3ad096875c1ce08",
+		}, mockPipeline); err != nil {
+			t.Fatal("error occurred", err.Error())
+		} else if len(mockPipeline.approvals) != 1 ||
+			aws.StringValue(mockPipeline.approvals[0].Result.Status) != codepipeline.ApprovalStatusRejected {
+			t.Fatal("expected a rejection", mockPipeline.approvals)
+		}
+	})
+
+	t.Run("other commit", func(t *testing.T) {
+		mockPipeline := &mockCodePipelineClient{}
+		if err := putApprovalResults(&approvalRequest{
+			Approve:    true,
+			Login:      "jane",
+			Owner:      "mrz1836",
+			Repository: "codepipeline-to-github",
+			SHA:        "9b2a1e4f5c6d7e8f9a0b1c2d3e4f5a6b7c8d9e0f",
+		}, mockPipeline); !errors.Is(err, errNoPendingApproval) {
+			t.Fatal("expected no pending approval", err)
+		} else if len(mockPipeline.approvals) != 0 {
+			t.Fatal("expected no approval result", mockPipeline.approvals)
+		}
+	})
+
+	t.Run("same commit from another repository", func(t *testing.T) {
+		mockPipeline := &mockCodePipelineClient{}
+		if err := putApprovalResults(&approvalRequest{
+			Approve:    true,
+			Login:      "jane",
+			Owner:      "someone-else",
+			Repository: "codepipeline-to-github",
+			SHA:        "25c0c3e61c4db2c2cde8b163b3ad096875c1ce08",
+		}, mockPipeline); !errors.Is(err, errNoPendingApproval) {
+			t.Fatal("expected no pending approval for a fork", err)
+		} else if len(mockPipeline.approvals) != 0 {
+			t.Fatal("expected no approval result", mockPipeline.approvals)
+		}
+	})
+}
+
+// TestGithubWebhookHandler will test githubWebhookHandler() (before any pipeline is called)
+func TestGithubWebhookHandler(t *testing.T) {
+	setHTTPEnvironment(t, httpAuthSecret)
+
+	review := `{"action":"submitted","review":{"state":"approved","user":{"login":"mallory"}},` +
+		`"pull_request":{"number":42,"head":{"sha":"25c0c3e61c4db2c2cde8b163b3ad096875c1ce08"}},` +
+		`"repository":{"full_name":"mrz1836/codepipeline-to-github"}}`
+
+	var tests = []struct {
+		name           string
+		secret         string
+		eventName      string
+		signature      string
+		expectedStatus int
+	}{
+		{"not configured", "", githubEventReview, signBody(review, "webhook-secret"), http.StatusNotFound},
+		{"invalid signature", "webhook-secret", githubEventReview, signBody(review, "other-secret"), http.StatusUnauthorized},
+		{"ignored event", "webhook-secret", "push", signBody(review, "webhook-secret"), http.StatusOK},
+		{"not an approver", "webhook-secret", githubEventReview, signBody(review, "webhook-secret"), http.StatusForbidden},
+	}
+
+	_ = os.Setenv("GITHUB_APPROVERS", "jane, john")
+	defer func() {
+		_ = os.Unsetenv("GITHUB_APPROVERS")
+		_ = os.Unsetenv("GITHUB_WEBHOOK_SECRET")
+	}()
+
+	for _, test := range tests {
+		_ = os.Setenv("GITHUB_WEBHOOK_SECRET", test.secret)
+		resetConfiguration()
+		r := httptest.NewRequest(http.MethodPost, githubWebhookPath, strings.NewReader(review))
+		r.Header.Set(githubEventHeader, test.eventName)
+		r.Header.Set(githubSignatureHeader, test.signature)
+		w := httptest.NewRecorder()
+		getHTTPHandler(r.URL.Path)(w, r)
+		if w.Code != test.expectedStatus {
+			t.Errorf("%s Failed: [%s] expected status [%d] got [%d] body [%s]", t.Name(), test.name, test.expectedStatus, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/codepipeline"
+	"github.com/aws/aws-sdk-go/service/codepipeline/codepipelineiface"
+)
+
+// Backfill defaults
+const (
+	defaultBackfillExecutions = 10
+	pipelineAll               = "all"
+)
+
+// backfillOptions are the options of the backfill command
+type backfillOptions struct {
+	DryRun   bool          // Log the statuses without posting them
+	Last     int           // Last N executions per pipeline (0 = no limit)
+	Pipeline string        // Pipeline name (or all)
+	Since    time.Duration // Executions started within the window (0 = no limit)
+}
+
+// runBackfill will run the backfill command (backfill -pipeline name -last 10 -since 24h -dry-run)
+func runBackfill(args []string) error {
+	var options backfillOptions
+	flags := flag.NewFlagSet("backfill", flag.ContinueOnError)
+	flags.BoolVar(&options.DryRun, "dry-run", false, "log the statuses without posting them")
+	flags.IntVar(&options.Last, "last", defaultBackfillExecutions, "last N executions per pipeline (0 = no limit)")
+	flags.StringVar(&options.Pipeline, "pipeline", pipelineAll, "pipeline name (or all)")
+	flags.DurationVar(&options.Since, "since", 0, "only executions started within the window (IE: 24h)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	// Load the configuration
+	if err := getConfiguration(); err != nil {
+		return err
+	}
+
+	posted, err := backfill(context.Background(), &options, codepipeline.New(awsSession), &githubPublisher{})
+	log.Printf("backfill complete, posted: %d statuses", posted)
+	return err
+}
+
+// backfill will post the final status of the recent executions of the pipeline(s)
+// In progress executions are skipped (their events are still to come), as are older runs of their commits
+func backfill(ctx context.Context, options *backfillOptions, pipeline codepipelineiface.CodePipelineAPI,
+	publisher statusPublisher,
+) (posted int, err error) {
+
+	// Get the pipeline(s)
+	var pipelineNames []string
+	if pipelineNames, err = getPipelineNames(options.Pipeline, pipeline); err != nil {
+		return
+	}
+
+	// Post the status of the newest execution of each commit (collect the errors per execution)
+	// The executions are listed newest first, older runs of the same commit would replace its status
+	var errs []error
+	seen := make(map[string]bool)
+	for _, pipelineName := range pipelineNames {
+		var executions []*codepipeline.PipelineExecutionSummary
+		if executions, err = getRecentExecutions(pipelineName, options.Last, options.Since, pipeline); err != nil {
+			errs = append(errs, fmt.Errorf("pipeline %s: %w", pipelineName, err))
+			continue
+		}
+		for _, execution := range executions {
+			var ok bool
+			executionID := aws.StringValue(execution.PipelineExecutionId)
+			if ok, err = backfillExecution(ctx, options.DryRun, pipelineName, execution, pipeline, publisher, seen); err != nil {
+				errs = append(errs, fmt.Errorf("pipeline %s execution %s: %w", pipelineName, executionID, err))
+			} else if ok {
+				posted++
+			}
+		}
+	}
+	err = errors.Join(errs...)
+	return
+}
+
+// backfillExecution will resolve the commit of the execution (getCommit) and post its status
+// Only the newest execution of a commit is posted, a commit with an in progress execution is skipped
+func backfillExecution(ctx context.Context, dryRun bool, pipelineName string,
+	execution *codepipeline.PipelineExecutionSummary, pipeline codepipelineiface.CodePipelineAPI,
+	publisher statusPublisher, seen map[string]bool,
+) (bool, error) {
+	executionID := aws.StringValue(execution.PipelineExecutionId)
+
+	// Create the status update
+	update, err := getExecutionUpdate(pipelineName, executionID, pipeline)
+	if err != nil || update == nil {
+		return false, err
+	}
+	key := getStatusKey(update)
+	if seen[key] {
+		return false, nil
+	}
+	seen[key] = true
+	if aws.StringValue(execution.Status) == codepipeline.PipelineExecutionStatusInProgress {
+		return false, nil
+	}
+
+	if dryRun {
+		log.Printf("dry-run: %s %s/%s@%s for pipeline: %s execution: %s",
+			update.State, update.Owner, update.Repository, update.Commit, pipelineName, executionID)
+		return true, nil
+	}
+	return true, publisher.Publish(withCredentials(ctx, getRouteCredentials(pipelineName)), update)
+}
+
+// getExecutionUpdate will resolve the commit of the execution (getCommit) and create its status update
+// Returns nil if the execution has no GitHub source
+func getExecutionUpdate(pipelineName, executionID string,
+	pipeline codepipelineiface.CodePipelineAPI,
+) (*statusUpdate, error) {
+
+	// Resolve the commit and status (GitHub sources only)
+	commit, status, revisionURL, err := getCommit(pipelineName, executionID, pipeline)
+	if err != nil {
+		return nil, err
+	} else if len(commit) == 0 || !isGitHubURL(revisionURL) {
+		return nil, nil
+	}
+
+	update, err := newStatusUpdate(
+		&sourceRevision{Commit: commit, RevisionURL: revisionURL}, nil, status, pipelineName, executionID,
+		fmt.Sprintf(
+			"https://%s.console.aws.amazon.com/codesuite/codepipeline/pipelines/%s/executions/%s",
+			getConfig().AWSRegion, pipelineName, executionID,
+		),
+	)
+	if err != nil {
+		return nil, err
+	}
+	applyRouteContext(update, eventKindPipeline, "", "")
+	return update, nil
+}
+
+// getStatusKey will return the key of the commit status the update replaces (owner/repo@sha and the context)
+func getStatusKey(update *statusUpdate) string {
+	return update.Owner + "/" + update.Repository + "@" + update.Commit + " " + update.Context
+}
+
+// getPipelineNames will return the pipeline name, or every pipeline (all)
+func getPipelineNames(pipelineName string, pipeline codepipelineiface.CodePipelineAPI) (names []string, err error) {
+	if pipelineName != pipelineAll {
+		return []string{pipelineName}, nil
+	}
+	err = pipeline.ListPipelinesPages(&codepipeline.ListPipelinesInput{},
+		func(page *codepipeline.ListPipelinesOutput, _ bool) bool {
+			for _, summary := range page.Pipelines {
+				names = append(names, aws.StringValue(summary.Name))
+			}
+			return true
+		},
+	)
+	return
+}
+
+// getRecentExecutions will return the last N executions and/or the executions started within the window
+// Executions are listed from newest to oldest
+func getRecentExecutions(pipelineName string, last int, since time.Duration,
+	pipeline codepipelineiface.CodePipelineAPI,
+) (executions []*codepipeline.PipelineExecutionSummary, err error) {
+	var cutoff time.Time
+	if since > 0 {
+		cutoff = time.Now().Add(-since)
+	}
+	err = pipeline.ListPipelineExecutionsPages(&codepipeline.ListPipelineExecutionsInput{
+		PipelineName: aws.String(pipelineName),
+	}, func(page *codepipeline.ListPipelineExecutionsOutput, _ bool) bool {
+		for _, execution := range page.PipelineExecutionSummaries {
+			if !cutoff.IsZero() && aws.TimeValue(execution.StartTime).Before(cutoff) {
+				return false
+			}
+			executions = append(executions, execution)
+			if last > 0 && len(executions) >= last {
+				return false
+			}
+		}
+		return true
+	})
+	return
+}
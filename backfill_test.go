@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestGetRecentExecutions will test getRecentExecutions()
+func TestGetRecentExecutions(t *testing.T) {
+	t.Parallel()
+
+	mockPipeline := &mockCodePipelineClient{}
+
+	var tests = []struct {
+		last          int
+		since         time.Duration
+		expectedCount int
+	}{
+		{0, 0, 3},
+		{2, 0, 2},
+		{0, 24 * time.Hour, 2},
+		{1, 24 * time.Hour, 1},
+	}
+
+	for _, test := range tests {
+		executions, err := getRecentExecutions("backfill", test.last, test.since, mockPipeline)
+		if err != nil {
+			t.Errorf("%s Failed: [%d/%s] error occurred [%s]", t.Name(), test.last, test.since, err.Error())
+		} else if len(executions) != test.expectedCount {
+			t.Errorf("%s Failed: [%d/%s] expected [%d] executions got [%d]", t.Name(), test.last, test.since, test.expectedCount, len(executions))
+		}
+	}
+
+	if _, err := getRecentExecutions("", 0, 0, mockPipeline); err == nil {
+		t.Fatal("error should have occurred")
+	}
+}
+
+// TestBackfill will test backfill() and getPipelineNames()
+func TestBackfill(t *testing.T) {
+
+	mockPipeline := &mockCodePipelineClient{}
+
+	t.Run("in progress commit", func(t *testing.T) {
+		publisher := &mockPublisher{name: publisherGitHub}
+		posted, err := backfill(context.Background(), &backfillOptions{Pipeline: "backfill"}, mockPipeline, publisher)
+		if err != nil {
+			t.Fatal("error occurred", err.Error())
+		} else if posted != 0 || publisher.published != 0 {
+			t.Fatal("expected the older runs of the in progress commit to be skipped", posted, publisher.published)
+		}
+	})
+
+	t.Run("retried commit", func(t *testing.T) {
+		publisher := &mockPublisher{name: publisherGitHub}
+		posted, err := backfill(context.Background(), &backfillOptions{Pipeline: "backfill-retry"}, mockPipeline, publisher)
+		if err != nil {
+			t.Fatal("error occurred", err.Error())
+		} else if posted != 1 || publisher.published != 1 {
+			t.Fatal("expected only the newest execution of the commit to be posted", posted, publisher.published)
+		} else if publisher.states[0] != "success" {
+			t.Fatal("expected the status of the newest execution", publisher.states)
+		}
+	})
+
+	t.Run("all pipelines within the window", func(t *testing.T) {
+		publisher := &mockPublisher{name: publisherGitHub}
+		posted, err := backfill(context.Background(), &backfillOptions{
+			Pipeline: pipelineAll,
+			Since:    24 * time.Hour,
+		}, mockPipeline, publisher)
+		if err != nil {
+			t.Fatal("error occurred", err.Error())
+		} else if posted != 0 || publisher.published != 0 {
+			t.Fatal("expected the commit of the in progress execution to be skipped", posted, publisher.published)
+		}
+	})
+
+	t.Run("dry-run", func(t *testing.T) {
+		publisher := &mockPublisher{name: publisherGitHub}
+		posted, err := backfill(context.Background(), &backfillOptions{DryRun: true, Pipeline: "backfill-retry"}, mockPipeline, publisher)
+		if err != nil {
+			t.Fatal("error occurred", err.Error())
+		} else if posted != 1 || publisher.published != 0 {
+			t.Fatal("expected nothing to be published on a dry-run", posted, publisher.published)
+		}
+	})
+
+	t.Run("publisher failure", func(t *testing.T) {
+		publisher := &mockPublisher{name: publisherGitHub, err: errors.New("github is down")}
+		if _, err := backfill(context.Background(), &backfillOptions{Pipeline: "backfill-retry"}, mockPipeline, publisher); err == nil {
+			t.Fatal("error should have occurred")
+		}
+	})
+
+	if names, err := getPipelineNames("my-pipeline", mockPipeline); err != nil || len(names) != 1 || names[0] != "my-pipeline" {
+		t.Fatal("expected the pipeline name", names, err)
+	} else if names, err = getPipelineNames(pipelineAll, mockPipeline); err != nil || len(names) != 3 {
+		t.Fatal("expected every pipeline", names, err)
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/codebuild"
+	"github.com/aws/aws-sdk-go/service/codebuild/codebuildiface"
+	"github.com/aws/aws-sdk-go/service/codecommit"
+)
+
+// CodeBuild defaults
+const (
+	buildContext               = "continuous-integration/codebuild"
+	buildInitiatorPipeline     = "codepipeline/"
+	detailTypeBuildStateChange = "CodeBuild Build State Change"
+	sourceTypeCodeCommit       = "CODECOMMIT"
+	sourceTypeGitHub           = "GITHUB"
+	sourceTypeGitHubEnterprise = "GITHUB_ENTERPRISE"
+)
+
+// commitSHA will match a full git commit sha
+var commitSHA = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// buildInformation is the additional information in a CodeBuild build state change event
+type buildInformation struct {
+	Initiator     string       `json:"initiator"`
+	Source        *buildSource `json:"source"`
+	SourceVersion string       `json:"source-version"`
+}
+
+// buildSource is the source of a CodeBuild build
+type buildSource struct {
+	Location string `json:"location"`
+	Type     string `json:"type"`
+}
+
+// validateBuildEvent will check the required parameters of a CodeBuild build state change
+func validateBuildEvent(ev event) error {
+	if len(ev.Detail.BuildID) == 0 {
+		return permanent(errors.New("missing event param build-id"))
+	}
+	if len(ev.Detail.ProjectName) == 0 {
+		return permanent(errors.New("missing event param project-name"))
+	}
+
+	// Builds started by a pipeline are reported by the pipeline events
+	if ev.Detail.AdditionalInformation != nil &&
+		strings.HasPrefix(ev.Detail.AdditionalInformation.Initiator, buildInitiatorPipeline) {
+		log.Printf("skipping build: %s started by: %s", ev.Detail.BuildID, ev.Detail.AdditionalInformation.Initiator)
+		return errSkipEvent
+	}
+	return nil
+}
+
+// processBuildEvent will post a commit status for a CodeBuild build state change (builds outside a pipeline)
+func processBuildEvent(ev event) error {
+
+	// Get the commit info from the build (event region and account)
+	eventSession := getEventSession(ev)
+	buildID := getBuildID(ev.Detail.BuildID)
+	revision, err := getBuildRevision(ev.Detail, getRegion(ev), buildID, codebuild.New(eventSession))
+	if err != nil {
+		return err
+	}
+
+	// Get the publishers (GitHub, CodeCommit, chat, webhooks)
+	var publishers []statusPublisher
+	if publishers, err = getPublishers(codecommit.New(eventSession), ""); err != nil {
+		return err
+	}
+
+	// Set up the link to the build
+	deepLink := fmt.Sprintf(
+		"https://%s.console.aws.amazon.com/codesuite/codebuild/projects/%s/build/%s/",
+		getRegion(ev), ev.Detail.ProjectName, url.PathEscape(buildID))
+
+	// Create the status update
+	var update *statusUpdate
+	status := getBuildStatus(ev.Detail.BuildStatus)
+	if update, err = newStatusUpdate(revision, nil, status, ev.Detail.ProjectName, buildID, deepLink); err != nil {
+		return err
+	}
+	update.Context = buildContext + "/" + ev.Detail.ProjectName
+	update.Description = getBuildDescription(ev.Detail.BuildStatus)
+
+	return publishStatus(context.Background(), publishers, update)
+}
+
+// getBuildID will return the build id (project:uuid) from the build ARN
+func getBuildID(buildARN string) string {
+	if index := strings.Index(buildARN, ":build/"); index >= 0 {
+		return buildARN[index+len(":build/"):]
+	}
+	return buildARN
+}
+
+// getBuildRevision will resolve the repository and commit from the build's source and source version
+//
+// Source versions that are not a commit (branches, tags, pr/123) use the resolved source version of the build
+func getBuildRevision(eventDetail *detail, region, buildID string,
+	codeBuildSvc codebuildiface.CodeBuildAPI,
+) (*sourceRevision, error) {
+
+	// Use the information from the event
+	var source buildSource
+	var commit string
+	if eventDetail.AdditionalInformation != nil {
+		commit = eventDetail.AdditionalInformation.SourceVersion
+		if eventDetail.AdditionalInformation.Source != nil {
+			source = *eventDetail.AdditionalInformation.Source
+		}
+	}
+
+	// Get the build details (resolved source version)
+	if !commitSHA.MatchString(commit) || len(source.Location) == 0 {
+		output, err := codeBuildSvc.BatchGetBuilds(&codebuild.BatchGetBuildsInput{
+			Ids: []*string{aws.String(buildID)},
+		})
+		if err != nil {
+			return nil, err
+		} else if output == nil || len(output.Builds) == 0 {
+			return nil, fmt.Errorf("missing build: %s", buildID)
+		}
+		build := output.Builds[0]
+		if len(aws.StringValue(build.ResolvedSourceVersion)) > 0 {
+			commit = aws.StringValue(build.ResolvedSourceVersion)
+		}
+		if build.Source != nil && len(source.Location) == 0 {
+			source.Location = aws.StringValue(build.Source.Location)
+			source.Type = aws.StringValue(build.Source.Type)
+		}
+	}
+	if len(commit) == 0 {
+		return nil, fmt.Errorf("unable to resolve the commit for build: %s", buildID)
+	}
+
+	// Resolve the repository from the source location
+	switch source.Type {
+	case sourceTypeGitHub, sourceTypeGitHubEnterprise:
+		return &sourceRevision{
+			ArtifactName: buildID,
+			Commit:       commit,
+			Repository:   source.Location,
+		}, nil
+	case sourceTypeCodeCommit:
+		repository := source.Location[strings.LastIndex(source.Location, "/")+1:]
+		revisionURL, err := url.Parse(fmt.Sprintf(
+			"https://%s.console.aws.amazon.com/codesuite/codecommit/repositories/%s/commit/%s",
+			region, repository, commit,
+		))
+		return &sourceRevision{
+			ArtifactName: buildID,
+			Commit:       commit,
+			RevisionURL:  revisionURL,
+		}, err
+	}
+	return nil, permanent(fmt.Errorf("unsupported source type: %s for build: %s", source.Type, buildID))
+}
+
+// getBuildStatus will return the GitHub status based on the build status
+func getBuildStatus(buildStatus string) string {
+	switch buildStatus {
+	case codebuild.StatusTypeInProgress:
+		return "pending"
+	case codebuild.StatusTypeSucceeded:
+		return "success"
+	case codebuild.StatusTypeFailed:
+		return "failure"
+	default:
+		return "error"
+	}
+}
+
+// getBuildDescription will return the status description based on the build status
+func getBuildDescription(buildStatus string) string {
+	switch buildStatus {
+	case codebuild.StatusTypeInProgress:
+		return "Build is in progress"
+	case codebuild.StatusTypeSucceeded:
+		return "Build succeeded"
+	case codebuild.StatusTypeFailed:
+		return "Build failed"
+	case codebuild.StatusTypeStopped:
+		return "Build was stopped"
+	case codebuild.StatusTypeTimedOut:
+		return "Build timed out"
+	default:
+		return "Build errored"
+	}
+}
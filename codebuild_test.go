@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/codebuild"
+	"github.com/aws/aws-sdk-go/service/codebuild/codebuildiface"
+)
+
+// Mocking codebuild client
+type mockCodeBuildClient struct {
+	codebuildiface.CodeBuildAPI
+}
+
+// BatchGetBuilds is a mock request for codebuild
+func (m *mockCodeBuildClient) BatchGetBuilds(input *codebuild.BatchGetBuildsInput) (*codebuild.BatchGetBuildsOutput, error) {
+	if len(input.Ids) == 0 {
+		return nil, fmt.Errorf("aws will reject: missing build ids")
+	}
+	if aws.StringValue(input.Ids[0]) == "missing-build:1234" {
+		return &codebuild.BatchGetBuildsOutput{BuildsNotFound: input.Ids}, nil
+	}
+	return &codebuild.BatchGetBuildsOutput{
+		Builds: []*codebuild.Build{{
+			Id:                    input.Ids[0],
+			ResolvedSourceVersion: aws.String("25c0c3e61c4db2c2cde8b163b3ad096875c1ce08"),
+			Source: &codebuild.ProjectSource{
+				Location: aws.String("https://github.com/mrz1836/codepipeline-to-github.git"),
+				Type:     aws.String(sourceTypeGitHub),
+			},
+			SourceVersion: aws.String("refs/heads/master"),
+		}},
+	}, nil
+}
+
+// TestGetBuildRevision will test getBuildRevision()
+func TestGetBuildRevision(t *testing.T) {
+	t.Parallel()
+
+	mockCodeBuild := &mockCodeBuildClient{}
+
+	t.Run("commit and source from the event", func(t *testing.T) {
+		revision, err := getBuildRevision(&detail{
+			AdditionalInformation: &buildInformation{
+				Source:        &buildSource{Location: "https://github.com/mrz1836/other-repo.git", Type: sourceTypeGitHub},
+				SourceVersion: "9b2a1e4f5c6d7e8f9a0b1c2d3e4f5a6b7c8d9e0f",
+			},
+		}, "us-east-1", "my-project:1234", mockCodeBuild)
+		if err != nil {
+			t.Fatal("error occurred", err.Error())
+		} else if revision.Commit != "9b2a1e4f5c6d7e8f9a0b1c2d3e4f5a6b7c8d9e0f" || revision.Repository != "https://github.com/mrz1836/other-repo.git" {
+			t.Fatal("revision was not as expected", revision)
+		}
+	})
+
+	t.Run("branch source version uses the resolved version", func(t *testing.T) {
+		revision, err := getBuildRevision(&detail{
+			AdditionalInformation: &buildInformation{
+				Source:        &buildSource{Location: "https://github.com/mrz1836/codepipeline-to-github.git", Type: sourceTypeGitHub},
+				SourceVersion: "refs/heads/master",
+			},
+		}, "us-east-1", "my-project:1234", mockCodeBuild)
+		if err != nil {
+			t.Fatal("error occurred", err.Error())
+		} else if revision.Commit != "25c0c3e61c4db2c2cde8b163b3ad096875c1ce08" {
+			t.Fatal("commit was not as expected", revision.Commit)
+		}
+	})
+
+	t.Run("codecommit source", func(t *testing.T) {
+		revision, err := getBuildRevision(&detail{
+			AdditionalInformation: &buildInformation{
+				Source:        &buildSource{Location: "https://git-codecommit.us-east-1.amazonaws.com/v1/repos/my-repo", Type: sourceTypeCodeCommit},
+				SourceVersion: "9b2a1e4f5c6d7e8f9a0b1c2d3e4f5a6b7c8d9e0f",
+			},
+		}, "us-east-1", "my-project:1234", mockCodeBuild)
+		if err != nil {
+			t.Fatal("error occurred", err.Error())
+		} else if !isCodeCommitURL(revision.RevisionURL) || getCodeCommitRepository(revision.RevisionURL) != "my-repo" {
+			t.Fatal("revision url was not as expected", revision.RevisionURL)
+		}
+	})
+
+	t.Run("unsupported source", func(t *testing.T) {
+		if _, err := getBuildRevision(&detail{
+			AdditionalInformation: &buildInformation{
+				Source:        &buildSource{Location: "bucket/source.zip", Type: "S3"},
+				SourceVersion: "9b2a1e4f5c6d7e8f9a0b1c2d3e4f5a6b7c8d9e0f",
+			},
+		}, "us-east-1", "my-project:1234", mockCodeBuild); err == nil {
+			t.Fatal("error should have occurred")
+		}
+	})
+
+	t.Run("missing build", func(t *testing.T) {
+		if _, err := getBuildRevision(&detail{}, "us-east-1", "missing-build:1234", mockCodeBuild); err == nil {
+			t.Fatal("error should have occurred")
+		}
+	})
+}
+
+// TestGetBuildID will test getBuildID()
+func TestGetBuildID(t *testing.T) {
+	t.Parallel()
+
+	if buildID := getBuildID("arn:aws:codebuild:us-east-1:123456789012:build/my-project:8745a7a9"); buildID != "my-project:8745a7a9" {
+		t.Fatal("build id was not as expected", buildID)
+	} else if buildID = getBuildID("my-project:8745a7a9"); buildID != "my-project:8745a7a9" {
+		t.Fatal("build id was not as expected", buildID)
+	}
+}
+
+// TestGetBuildStatus will test getBuildStatus()
+func TestGetBuildStatus(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		buildStatus string
+		expected    string
+	}{
+		{"IN_PROGRESS", "pending"},
+		{"SUCCEEDED", "success"},
+		{"FAILED", "failure"},
+		{"STOPPED", "error"},
+		{"TIMED_OUT", "error"},
+	}
+
+	for _, test := range tests {
+		if status := getBuildStatus(test.buildStatus); status != test.expected {
+			t.Errorf("%s Failed: [%s] expected [%s] got [%s]", t.Name(), test.buildStatus, test.expected, status)
+		}
+	}
+}
+
+// TestProcessBuildEvent will test the CodeBuild path of ProcessEvent()
+func TestProcessBuildEvent(t *testing.T) {
+
+	t.Run("missing param build-id", func(t *testing.T) {
+		if err := ProcessEvent(event{
+			Detail:     &detail{ProjectName: "my-project"},
+			DetailType: detailTypeBuildStateChange,
+		}); err == nil {
+			t.Fatal("error failed to trigger with an invalid request")
+		}
+	})
+
+	t.Run("skip builds started by a pipeline", func(t *testing.T) {
+		if err := ProcessEvent(event{
+			Detail: &detail{
+				AdditionalInformation: &buildInformation{Initiator: "codepipeline/my-pipeline"},
+				BuildID:               "arn:aws:codebuild:us-east-1:123456789012:build/my-project:8745a7a9",
+				BuildStatus:           "SUCCEEDED",
+				ProjectName:           "my-project",
+			},
+			DetailType: detailTypeBuildStateChange,
+		}); err != nil {
+			t.Fatal("error occurred", err.Error())
+		}
+	})
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/codecommit"
+	"github.com/aws/aws-sdk-go/service/codecommit/codecommitiface"
+)
+
+// CodeCommit defaults
+const (
+	codeCommitApprove = "APPROVE"
+	codeCommitRevoke  = "REVOKE"
+	codeCommitOpen    = "OPEN"
+)
+
+// isCodeCommitURL will return true if the revision url points to a CodeCommit repository (console url)
+func isCodeCommitURL(revisionURL *url.URL) bool {
+	if revisionURL == nil || !strings.HasSuffix(revisionURL.Host, "console.aws.amazon.com") {
+		return false
+	}
+	return len(getCodeCommitRepository(revisionURL)) > 0
+}
+
+// getCodeCommitRepository will return the repository name from a CodeCommit console url
+//
+// Supports both url formats used by CodePipeline:
+// https://us-east-1.console.aws.amazon.com/codesuite/codecommit/repositories/<repo>/commit/<sha>
+// https://console.aws.amazon.com/codecommit/home?region=us-east-1#/repository/<repo>/commit/<sha>
+func getCodeCommitRepository(revisionURL *url.URL) string {
+	path := revisionURL.Path
+	if len(revisionURL.Fragment) > 0 {
+		path = revisionURL.Fragment
+	}
+	if !strings.Contains(revisionURL.Path, "codecommit") {
+		return ""
+	}
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	for i := 0; i < len(parts)-1; i++ {
+		if parts[i] == "repositories" || parts[i] == "repository" {
+			return parts[i+1]
+		}
+	}
+	return ""
+}
+
+// postCodeCommitFeedback will comment on every open pull request where the source commit matches
+func postCodeCommitFeedback(ctx context.Context, codeCommitSvc codecommitiface.CodeCommitAPI, update *statusUpdate) error {
+
+	// Find the matching pull requests
+	pullRequests, err := getCodeCommitPullRequests(ctx, codeCommitSvc, update.Repository, update.Commit)
+	if err != nil {
+		return err
+	} else if len(pullRequests) == 0 {
+		log.Printf("no open pull requests found in repository: %s for commit: %s", update.Repository, update.Commit)
+		return nil
+	}
+
+	// Comment (and optionally approve) on each pull request
+	var errs []error
+	for _, pullRequest := range pullRequests {
+		if err = commentOnPullRequest(ctx, codeCommitSvc, pullRequest, update); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if getConfig().CodeCommitApprovalState {
+			if err = updatePullRequestApproval(ctx, codeCommitSvc, pullRequest, update.State); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// getCodeCommitPullRequests will return all open pull requests where the source commit matches
+func getCodeCommitPullRequests(ctx context.Context, codeCommitSvc codecommitiface.CodeCommitAPI,
+	repository, commit string,
+) (pullRequests []*codecommit.PullRequest, err error) {
+
+	// Get all the open pull request ids
+	var ids []*string
+	if err = codeCommitSvc.ListPullRequestsPagesWithContext(ctx, &codecommit.ListPullRequestsInput{
+		PullRequestStatus: aws.String(codeCommitOpen),
+		RepositoryName:    aws.String(repository),
+	}, func(page *codecommit.ListPullRequestsOutput, _ bool) bool {
+		ids = append(ids, page.PullRequestIds...)
+		return true
+	}); err != nil {
+		return
+	}
+
+	// Find the pull requests with a matching source commit
+	for _, id := range ids {
+		var output *codecommit.GetPullRequestOutput
+		if output, err = codeCommitSvc.GetPullRequestWithContext(ctx, &codecommit.GetPullRequestInput{
+			PullRequestId: id,
+		}); err != nil {
+			return
+		} else if output == nil || output.PullRequest == nil {
+			continue
+		}
+		if getPullRequestTarget(output.PullRequest, repository, commit) != nil {
+			pullRequests = append(pullRequests, output.PullRequest)
+		}
+	}
+	return
+}
+
+// getPullRequestTarget will return the pull request target for the repository and source commit
+func getPullRequestTarget(pullRequest *codecommit.PullRequest, repository, commit string) *codecommit.PullRequestTarget {
+	for _, target := range pullRequest.PullRequestTargets {
+		if aws.StringValue(target.RepositoryName) == repository && aws.StringValue(target.SourceCommit) == commit {
+			return target
+		}
+	}
+	return nil
+}
+
+// commentOnPullRequest will post the pipeline outcome as a comment on the pull request
+func commentOnPullRequest(ctx context.Context, codeCommitSvc codecommitiface.CodeCommitAPI,
+	pullRequest *codecommit.PullRequest, update *statusUpdate,
+) error {
+	target := getPullRequestTarget(pullRequest, update.Repository, update.Commit)
+	_, err := codeCommitSvc.PostCommentForPullRequestWithContext(ctx, &codecommit.PostCommentForPullRequestInput{
+		AfterCommitId:      target.SourceCommit,
+		BeforeCommitId:     target.DestinationCommit,
+		ClientRequestToken: aws.String(getCommentRequestToken(update, aws.StringValue(pullRequest.PullRequestId))),
+		Content: aws.String(fmt.Sprintf(
+			"CodePipeline **%s** execution `%s`: **%s**\n\n%s\n\n[View execution](%s)",
+			update.Pipeline, update.ExecutionID, update.State, update.Description, update.TargetURL,
+		)),
+		PullRequestId:  pullRequest.PullRequestId,
+		RepositoryName: aws.String(update.Repository),
+	})
+	return err
+}
+
+// getCommentRequestToken will return the idempotency token of the comment (execution, state, context and pull request)
+// Updates of the same execution and state with another context (IE: an approval and the pipeline) are separate comments
+func getCommentRequestToken(update *statusUpdate, pullRequestID string) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		update.ExecutionID, update.State, update.Context, pullRequestID,
+	}, "|")))
+	return hex.EncodeToString(sum[:])
+}
+
+// updatePullRequestApproval will approve (success) or revoke (failure) the pull request approval state
+func updatePullRequestApproval(ctx context.Context, codeCommitSvc codecommitiface.CodeCommitAPI,
+	pullRequest *codecommit.PullRequest, status string,
+) error {
+
+	// Pending executions do not change the approval state
+	var state string
+	switch status {
+	case "success":
+		state = codeCommitApprove
+	case "failure":
+		state = codeCommitRevoke
+	default:
+		return nil
+	}
+
+	_, err := codeCommitSvc.UpdatePullRequestApprovalStateWithContext(ctx, &codecommit.UpdatePullRequestApprovalStateInput{
+		ApprovalState: aws.String(state),
+		PullRequestId: pullRequest.PullRequestId,
+		RevisionId:    pullRequest.RevisionId,
+	})
+	return err
+}
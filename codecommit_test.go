@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/codecommit"
+	"github.com/aws/aws-sdk-go/service/codecommit/codecommitiface"
+)
+
+// Mocking codecommit client
+type mockCodeCommitClient struct {
+	codecommitiface.CodeCommitAPI
+	approvals []string
+	comments  []string
+	tokens    map[string]string // Content by ClientRequestToken
+}
+
+// ListPullRequestsPagesWithContext is a mock request for codecommit
+func (m *mockCodeCommitClient) ListPullRequestsPagesWithContext(_ aws.Context, input *codecommit.ListPullRequestsInput,
+	fn func(*codecommit.ListPullRequestsOutput, bool) bool, _ ...request.Option,
+) error {
+	if aws.StringValue(input.RepositoryName) == "missing-repo" {
+		return fmt.Errorf("RepositoryDoesNotExistException")
+	}
+	fn(&codecommit.ListPullRequestsOutput{PullRequestIds: aws.StringSlice([]string{"1", "2"})}, true)
+	return nil
+}
+
+// GetPullRequestWithContext is a mock request for codecommit
+func (m *mockCodeCommitClient) GetPullRequestWithContext(_ aws.Context, input *codecommit.GetPullRequestInput,
+	_ ...request.Option,
+) (*codecommit.GetPullRequestOutput, error) {
+	sourceCommit := "25c0c3e61c4db2c2cde8b163b3ad096875c1ce08"
+	if aws.StringValue(input.PullRequestId) == "2" {
+		sourceCommit = "some-other-commit"
+	}
+	return &codecommit.GetPullRequestOutput{
+		PullRequest: &codecommit.PullRequest{
+			PullRequestId: input.PullRequestId,
+			PullRequestTargets: []*codecommit.PullRequestTarget{{
+				DestinationCommit: aws.String("destination-commit"),
+				RepositoryName:    aws.String("my-repo"),
+				SourceCommit:      aws.String(sourceCommit),
+			}},
+			RevisionId: aws.String("revision-" + aws.StringValue(input.PullRequestId)),
+		},
+	}, nil
+}
+
+// PostCommentForPullRequestWithContext is a mock request for codecommit
+func (m *mockCodeCommitClient) PostCommentForPullRequestWithContext(_ aws.Context,
+	input *codecommit.PostCommentForPullRequestInput, _ ...request.Option,
+) (*codecommit.PostCommentForPullRequestOutput, error) {
+	if m.tokens == nil {
+		m.tokens = map[string]string{}
+	}
+	token, content := aws.StringValue(input.ClientRequestToken), aws.StringValue(input.Content)
+	if previous, ok := m.tokens[token]; ok && previous != content {
+		return nil, fmt.Errorf("%s: same token with other content", codecommit.ErrCodeIdempotencyParameterMismatchException)
+	}
+	m.tokens[token] = content
+	m.comments = append(m.comments, aws.StringValue(input.PullRequestId))
+	return &codecommit.PostCommentForPullRequestOutput{}, nil
+}
+
+// UpdatePullRequestApprovalStateWithContext is a mock request for codecommit
+func (m *mockCodeCommitClient) UpdatePullRequestApprovalStateWithContext(_ aws.Context,
+	input *codecommit.UpdatePullRequestApprovalStateInput, _ ...request.Option,
+) (*codecommit.UpdatePullRequestApprovalStateOutput, error) {
+	m.approvals = append(m.approvals, aws.StringValue(input.ApprovalState))
+	return &codecommit.UpdatePullRequestApprovalStateOutput{}, nil
+}
+
+// TestIsCodeCommitURL will test isCodeCommitURL() and getCodeCommitRepository()
+func TestIsCodeCommitURL(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		revisionURL        string
+		expectedCodeCommit bool
+		expectedRepository string
+	}{
+		{"https://us-east-1.console.aws.amazon.com/codesuite/codecommit/repositories/my-repo/commit/25c0c3e?region=us-east-1", true, "my-repo"},
+		{"https://console.aws.amazon.com/codecommit/home?region=us-east-1#/repository/my-repo/commit/25c0c3e", true, "my-repo"},
+		{"https://github.com/mrz1836/codepipeline-to-github/commit/25c0c3e", false, ""},
+		{"https://us-east-1.console.aws.amazon.com/codesuite/codepipeline/pipelines/my-pipeline", false, ""},
+	}
+
+	for _, test := range tests {
+		revisionURL, err := url.Parse(test.revisionURL)
+		if err != nil {
+			t.Fatal("error occurred", err.Error())
+		}
+		if isCodeCommitURL(revisionURL) != test.expectedCodeCommit {
+			t.Errorf("%s Failed: [%s] expected codecommit [%t]", t.Name(), test.revisionURL, test.expectedCodeCommit)
+		} else if test.expectedCodeCommit && getCodeCommitRepository(revisionURL) != test.expectedRepository {
+			t.Errorf("%s Failed: [%s] expected repository [%s]", t.Name(), test.revisionURL, test.expectedRepository)
+		}
+	}
+
+	if isCodeCommitURL(nil) {
+		t.Fatal("nil url should not be a codecommit url")
+	}
+}
+
+// newCodeCommitUpdate will return a status update for a CodeCommit repository
+func newCodeCommitUpdate(repository, state string) *statusUpdate {
+	return &statusUpdate{
+		Commit:      "25c0c3e61c4db2c2cde8b163b3ad096875c1ce08",
+		ExecutionID: "12345",
+		Pipeline:    "my-pipeline",
+		Provider:    providerCodeCommit,
+		Repository:  repository,
+		State:       state,
+		TargetURL:   "https://link",
+	}
+}
+
+// TestPostCodeCommitFeedback will test postCodeCommitFeedback()
+func TestPostCodeCommitFeedback(t *testing.T) {
+
+	t.Run("comment on matching pull requests", func(t *testing.T) {
+		setConfig(t, func(c *loadedConfiguration) {
+			c.CodeCommitApprovalState = false
+		})
+		mockCodeCommit := &mockCodeCommitClient{}
+		err := postCodeCommitFeedback(context.Background(), mockCodeCommit, newCodeCommitUpdate("my-repo", "success"))
+		if err != nil {
+			t.Fatal("error occurred", err.Error())
+		} else if len(mockCodeCommit.comments) != 1 || mockCodeCommit.comments[0] != "1" {
+			t.Fatal("expected a single comment on pull request 1", mockCodeCommit.comments)
+		} else if len(mockCodeCommit.approvals) != 0 {
+			t.Fatal("expected no approval changes", mockCodeCommit.approvals)
+		}
+	})
+
+	t.Run("approval state", func(t *testing.T) {
+		setConfig(t, func(c *loadedConfiguration) {
+			c.CodeCommitApprovalState = true
+		})
+		mockCodeCommit := &mockCodeCommitClient{}
+		for _, status := range []string{"pending", "success", "failure"} {
+			if err := postCodeCommitFeedback(context.Background(), mockCodeCommit, newCodeCommitUpdate("my-repo", status)); err != nil {
+				t.Fatal("error occurred", err.Error())
+			}
+		}
+		if len(mockCodeCommit.approvals) != 2 {
+			t.Fatal("expected two approval changes", mockCodeCommit.approvals)
+		} else if mockCodeCommit.approvals[0] != codeCommitApprove || mockCodeCommit.approvals[1] != codeCommitRevoke {
+			t.Fatal("approval states were not as expected", mockCodeCommit.approvals)
+		}
+	})
+
+	t.Run("same state with another context", func(t *testing.T) {
+		setConfig(t, func(c *loadedConfiguration) {
+			c.CodeCommitApprovalState = true
+		})
+		mockCodeCommit := &mockCodeCommitClient{}
+		approval := newCodeCommitUpdate("my-repo", "success")
+		approval.Context = githubContext + "/approval"
+		approval.Description = "Approval succeeded"
+		pipeline := newCodeCommitUpdate("my-repo", "success")
+		pipeline.Context = githubContext
+		pipeline.Description = "Pipeline execution succeeded"
+		for _, update := range []*statusUpdate{approval, pipeline, pipeline} {
+			if err := postCodeCommitFeedback(context.Background(), mockCodeCommit, update); err != nil {
+				t.Fatal("error occurred", err.Error())
+			}
+		}
+		if len(mockCodeCommit.tokens) != 2 {
+			t.Fatal("expected a comment per context", mockCodeCommit.tokens)
+		} else if len(mockCodeCommit.approvals) != 3 {
+			t.Fatal("expected the approval state of every update", mockCodeCommit.approvals)
+		}
+	})
+
+	t.Run("missing repository", func(t *testing.T) {
+		mockCodeCommit := &mockCodeCommitClient{}
+		if err := postCodeCommitFeedback(context.Background(), mockCodeCommit, newCodeCommitUpdate("missing-repo", "success")); err == nil {
+			t.Fatal("error should have occurred")
+		}
+	})
+}
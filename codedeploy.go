@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/codedeploy"
+	"github.com/aws/aws-sdk-go/service/codedeploy/codedeployiface"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// CodeDeploy defaults
+const (
+	deploymentPayloadKey            = "codedeploy_deployment_id"
+	deploymentStateFailure          = "FAILURE"
+	deploymentStateReady            = "READY"
+	deploymentStateStart            = "START"
+	deploymentStateStop             = "STOP"
+	deploymentStateSuccess          = "SUCCESS"
+	detailTypeDeploymentStateChange = "CodeDeploy Deployment State-change Notification"
+)
+
+// validateDeploymentEvent will check the required parameters of a CodeDeploy deployment state change
+func validateDeploymentEvent(ev event) error {
+	if len(ev.Detail.DeploymentID) == 0 {
+		return permanent(errors.New("missing event param deploymentId"))
+	}
+	return nil
+}
+
+// processDeploymentEvent will update a GitHub deployment for a CodeDeploy deployment state change
+func processDeploymentEvent(ev event) error {
+
+	// Get the deployment details (event region and account)
+	eventSession := getEventSession(ev)
+	deployment, err := getDeployment(ev.Detail.DeploymentID, codedeploy.New(eventSession))
+	if err != nil {
+		return err
+	}
+
+	// Resolve the deployed revision (GitHub or S3 bundle with commit metadata)
+	var revision *sourceRevision
+	if revision, err = getDeploymentRevision(deployment, s3.New(eventSession)); err != nil {
+		return err
+	}
+
+	return updateGitHubDeployment(context.Background(), deployment, revision, ev.Detail.State, getRegion(ev))
+}
+
+// getDeployment will return the details of a CodeDeploy deployment
+func getDeployment(deploymentID string, codeDeploySvc codedeployiface.CodeDeployAPI) (*codedeploy.DeploymentInfo, error) {
+	output, err := codeDeploySvc.GetDeployment(&codedeploy.GetDeploymentInput{
+		DeploymentId: aws.String(deploymentID),
+	})
+	if err != nil {
+		return nil, err
+	} else if output == nil || output.DeploymentInfo == nil {
+		return nil, fmt.Errorf("missing deployment: %s", deploymentID)
+	}
+	return output.DeploymentInfo, nil
+}
+
+// getDeploymentRevision will resolve the repository and commit of the deployed revision
+func getDeploymentRevision(deployment *codedeploy.DeploymentInfo, s3Svc s3iface.S3API) (*sourceRevision, error) {
+	revision := deployment.Revision
+	if revision == nil {
+		return nil, fmt.Errorf("missing revision for deployment: %s", aws.StringValue(deployment.DeploymentId))
+	}
+	switch aws.StringValue(revision.RevisionType) {
+	case codedeploy.RevisionLocationTypeGitHub:
+		if revision.GitHubLocation == nil {
+			break
+		}
+		return &sourceRevision{
+			Commit:     aws.StringValue(revision.GitHubLocation.CommitId),
+			Repository: aws.StringValue(revision.GitHubLocation.Repository),
+		}, nil
+	case codedeploy.RevisionLocationTypeS3:
+		if revision.S3Location == nil {
+			break
+		}
+		return getS3ObjectRevision(
+			s3Svc, aws.StringValue(revision.S3Location.Bucket), aws.StringValue(revision.S3Location.Key),
+			aws.StringValue(revision.S3Location.Version),
+		)
+	}
+	return nil, permanent(fmt.Errorf(
+		"unsupported revision type: %s for deployment: %s",
+		aws.StringValue(revision.RevisionType), aws.StringValue(deployment.DeploymentId),
+	))
+}
+
+// updateGitHubDeployment will find (or create) the GitHub deployment and add a status for the state
+func updateGitHubDeployment(ctx context.Context, deployment *codedeploy.DeploymentInfo,
+	revision *sourceRevision, state, region string,
+) error {
+
+	// Break apart the components
+	owner, repo, err := parseRepository(revision.Repository)
+	if err != nil {
+		return err
+	}
+
+	// Find the existing GitHub deployment or create a new one
+	deploymentID := aws.StringValue(deployment.DeploymentId)
+	environment := aws.StringValue(deployment.DeploymentGroupName)
+	var githubDeploymentID int64
+	if githubDeploymentID, err = getGitHubDeploymentID(ctx, owner, repo, revision.Commit, environment, deploymentID); err != nil {
+		return err
+	} else if githubDeploymentID == 0 {
+		var created *githubDeployment
+		if created, err = createDeployment(ctx, owner, repo, &deploymentRequest{
+			AutoMerge: false,
+			Description: fmt.Sprintf(
+				"CodeDeploy %s/%s %s", aws.StringValue(deployment.ApplicationName), environment, deploymentID,
+			),
+			Environment:      environment,
+			Payload:          map[string]interface{}{deploymentPayloadKey: deploymentID},
+			Ref:              revision.Commit,
+			RequiredContexts: []string{},
+		}); err != nil {
+			return err
+		}
+		if created != nil { // Nil on a dry-run
+			githubDeploymentID = created.ID
+		}
+		log.Printf("created GitHub deployment: %d for deployment: %s", githubDeploymentID, deploymentID)
+	}
+
+	// Add the deployment status
+	githubState, description := getDeploymentState(state, deployment)
+	return createDeploymentStatus(ctx, owner, repo, githubDeploymentID, &deploymentStatusRequest{
+		AutoInactive: true,
+		Description:  description,
+		LogURL: fmt.Sprintf(
+			"https://%s.console.aws.amazon.com/codesuite/codedeploy/deployments/%s?region=%s",
+			region, deploymentID, region,
+		),
+		State: githubState,
+	})
+}
+
+// getGitHubDeploymentID will return the GitHub deployment id created for the CodeDeploy deployment (0 if not found)
+func getGitHubDeploymentID(ctx context.Context, owner, repo, commit, environment, deploymentID string) (int64, error) {
+	deployments, err := getDeployments(ctx, owner, repo, commit, environment)
+	if err != nil {
+		return 0, err
+	}
+	for _, deployment := range deployments {
+		var payload map[string]interface{}
+		if err = json.Unmarshal(deployment.Payload, &payload); err != nil {
+			continue
+		}
+		if id, ok := payload[deploymentPayloadKey].(string); ok && id == deploymentID {
+			return deployment.ID, nil
+		}
+	}
+	return 0, nil
+}
+
+// getDeploymentState will return the GitHub deployment state and description based on the CodeDeploy state
+func getDeploymentState(state string, deployment *codedeploy.DeploymentInfo) (githubState, description string) {
+
+	// Rollback deployments (re-deploying the previous revision)
+	prefix := "Deployment"
+	if aws.StringValue(deployment.Creator) == codedeploy.DeploymentCreatorCodeDeployRollback &&
+		deployment.RollbackInfo != nil {
+		prefix = "Rollback of " + aws.StringValue(deployment.RollbackInfo.RollbackTriggeringDeploymentId)
+	}
+
+	switch state {
+	case deploymentStateStart:
+		githubState, description = "in_progress", prefix+" started"
+	case deploymentStateReady:
+		githubState, description = "in_progress", prefix+" is ready to reroute traffic"
+	case deploymentStateSuccess:
+		githubState, description = "success", prefix+" succeeded"
+	case deploymentStateStop:
+		githubState, description = "error", prefix+" was stopped"
+	default:
+		githubState, description = "failure", prefix+" failed"
+	}
+
+	// Failed (or stopped) deployments that were rolled back
+	if (state == deploymentStateFailure || state == deploymentStateStop) && deployment.RollbackInfo != nil &&
+		len(aws.StringValue(deployment.RollbackInfo.RollbackDeploymentId)) > 0 {
+		description += ", rolled back by " + aws.StringValue(deployment.RollbackInfo.RollbackDeploymentId)
+	}
+	return
+}
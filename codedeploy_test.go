@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/codedeploy"
+	"github.com/aws/aws-sdk-go/service/codedeploy/codedeployiface"
+)
+
+// Mocking codedeploy client
+type mockCodeDeployClient struct {
+	codedeployiface.CodeDeployAPI
+}
+
+// GetDeployment is a mock request for codedeploy
+func (m *mockCodeDeployClient) GetDeployment(input *codedeploy.GetDeploymentInput) (*codedeploy.GetDeploymentOutput, error) {
+	deploymentID := aws.StringValue(input.DeploymentId)
+	info := &codedeploy.DeploymentInfo{
+		ApplicationName:     aws.String("some-application"),
+		DeploymentGroupName: aws.String("production"),
+		DeploymentId:        input.DeploymentId,
+	}
+	switch deploymentID {
+	case "d-GITHUB":
+		info.Revision = &codedeploy.RevisionLocation{
+			GitHubLocation: &codedeploy.GitHubLocation{
+				CommitId:   aws.String("25c0c3e61c4db2c2cde8b163b3ad096875c1ce08"),
+				Repository: aws.String("mrz1836/codepipeline-to-github"),
+			},
+			RevisionType: aws.String(codedeploy.RevisionLocationTypeGitHub),
+		}
+	case "d-S3":
+		info.Revision = &codedeploy.RevisionLocation{
+			RevisionType: aws.String(codedeploy.RevisionLocationTypeS3),
+			S3Location: &codedeploy.S3Location{
+				Bucket:  aws.String("artifact-bucket"),
+				Key:     aws.String("app/source.zip"),
+				Version: aws.String("version-metadata"),
+			},
+		}
+	case "d-STRING":
+		info.Revision = &codedeploy.RevisionLocation{
+			RevisionType: aws.String(codedeploy.RevisionLocationTypeString),
+		}
+	case "d-MISSING":
+		return nil, fmt.Errorf("DeploymentDoesNotExistException")
+	}
+	return &codedeploy.GetDeploymentOutput{DeploymentInfo: info}, nil
+}
+
+// TestGetDeploymentRevision will test getDeployment() and getDeploymentRevision()
+func TestGetDeploymentRevision(t *testing.T) {
+
+	setConfig(t, func(c *loadedConfiguration) {
+		c.S3MetadataCommitKey = "commit"
+		c.S3MetadataRepositoryKey = "repository"
+	})
+	s3Svc := newS3StandIn(t)
+	mockCodeDeploy := &mockCodeDeployClient{}
+
+	var tests = []struct {
+		deploymentID       string
+		expectedRepository string
+		expectedError      bool
+	}{
+		{"d-GITHUB", "mrz1836/codepipeline-to-github", false},
+		{"d-S3", "https://github.com/mrz1836/codepipeline-to-github.git", false},
+		{"d-STRING", "", true},
+		{"d-NO-REVISION", "", true},
+		{"d-MISSING", "", true},
+	}
+
+	for _, test := range tests {
+		deployment, err := getDeployment(test.deploymentID, mockCodeDeploy)
+		var revision *sourceRevision
+		if err == nil {
+			revision, err = getDeploymentRevision(deployment, s3Svc)
+		}
+		if err == nil && test.expectedError {
+			t.Errorf("%s Failed: [%s] expected to throw an error, but no error", t.Name(), test.deploymentID)
+		} else if err != nil && !test.expectedError {
+			t.Errorf("%s Failed: [%s] error occurred [%s]", t.Name(), test.deploymentID, err.Error())
+		} else if err == nil && (revision.Repository != test.expectedRepository ||
+			revision.Commit != "25c0c3e61c4db2c2cde8b163b3ad096875c1ce08") {
+			t.Errorf("%s Failed: [%s] unexpected revision [%s@%s]", t.Name(), test.deploymentID, revision.Repository, revision.Commit)
+		}
+	}
+}
+
+// TestGetDeploymentState will test getDeploymentState()
+func TestGetDeploymentState(t *testing.T) {
+	t.Parallel()
+
+	rollback := &codedeploy.DeploymentInfo{
+		Creator: aws.String(codedeploy.DeploymentCreatorCodeDeployRollback),
+		RollbackInfo: &codedeploy.RollbackInfo{
+			RollbackTriggeringDeploymentId: aws.String("d-FAILED"),
+		},
+	}
+	rolledBack := &codedeploy.DeploymentInfo{
+		Creator: aws.String(codedeploy.DeploymentCreatorUser),
+		RollbackInfo: &codedeploy.RollbackInfo{
+			RollbackDeploymentId: aws.String("d-ROLLBACK"),
+		},
+	}
+
+	var tests = []struct {
+		state               string
+		deployment          *codedeploy.DeploymentInfo
+		expectedState       string
+		expectedDescription string
+	}{
+		{deploymentStateStart, &codedeploy.DeploymentInfo{}, "in_progress", "Deployment started"},
+		{deploymentStateReady, &codedeploy.DeploymentInfo{}, "in_progress", "Deployment is ready to reroute traffic"},
+		{deploymentStateSuccess, &codedeploy.DeploymentInfo{}, "success", "Deployment succeeded"},
+		{deploymentStateFailure, &codedeploy.DeploymentInfo{}, "failure", "Deployment failed"},
+		{deploymentStateStop, &codedeploy.DeploymentInfo{}, "error", "Deployment was stopped"},
+		{deploymentStateFailure, rolledBack, "failure", "Deployment failed, rolled back by d-ROLLBACK"},
+		{deploymentStateSuccess, rollback, "success", "Rollback of d-FAILED succeeded"},
+	}
+
+	for _, test := range tests {
+		state, description := getDeploymentState(test.state, test.deployment)
+		if state != test.expectedState || description != test.expectedDescription {
+			t.Errorf("%s Failed: [%s] expected [%s: %s] got [%s: %s]",
+				t.Name(), test.state, test.expectedState, test.expectedDescription, state, description)
+		}
+	}
+}
+
+// TestUpdateGitHubDeployment will test updateGitHubDeployment()
+func TestUpdateGitHubDeployment(t *testing.T) {
+
+	var created, statuses []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/repos/mrz1836/codepipeline-to-github/deployments":
+			_ = json.NewEncoder(w).Encode([]githubDeployment{
+				{ID: 1, Payload: json.RawMessage(`"created by someone else"`)},
+				{ID: 2, Payload: json.RawMessage(`{"codedeploy_deployment_id":"d-EXISTING"}`)},
+			})
+		case r.Method == http.MethodPost && r.URL.Path == "/repos/mrz1836/codepipeline-to-github/deployments":
+			var request deploymentRequest
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Environment != "production" {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				return
+			}
+			created = append(created, request.Payload[deploymentPayloadKey].(string))
+			w.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(w).Encode(githubDeployment{ID: 3})
+		case r.Method == http.MethodPost:
+			var request deploymentStatusRequest
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				return
+			}
+			statuses = append(statuses, r.URL.Path+":"+request.State)
+			w.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(w).Encode(map[string]int64{"id": 10})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	setConfig(t, func(c *loadedConfiguration) {
+		c.GithubAPIURL = server.URL
+		c.GithubAccessToken = "test-token"
+	})
+
+	revision := &sourceRevision{
+		Commit:     "25c0c3e61c4db2c2cde8b163b3ad096875c1ce08",
+		Repository: "mrz1836/codepipeline-to-github",
+	}
+
+	// Existing GitHub deployment
+	if err := updateGitHubDeployment(context.Background(), &codedeploy.DeploymentInfo{
+		DeploymentGroupName: aws.String("production"),
+		DeploymentId:        aws.String("d-EXISTING"),
+	}, revision, deploymentStateSuccess, "us-east-1"); err != nil {
+		t.Fatal("error occurred", err.Error())
+	}
+
+	// New GitHub deployment
+	if err := updateGitHubDeployment(context.Background(), &codedeploy.DeploymentInfo{
+		DeploymentGroupName: aws.String("production"),
+		DeploymentId:        aws.String("d-NEW"),
+	}, revision, deploymentStateStart, "us-east-1"); err != nil {
+		t.Fatal("error occurred", err.Error())
+	}
+
+	if len(created) != 1 || created[0] != "d-NEW" {
+		t.Fatal("expected a single deployment to be created", created)
+	}
+	expected := []string{
+		"/repos/mrz1836/codepipeline-to-github/deployments/2/statuses:success",
+		"/repos/mrz1836/codepipeline-to-github/deployments/3/statuses:in_progress",
+	}
+	if len(statuses) != len(expected) || statuses[0] != expected[0] || statuses[1] != expected[1] {
+		t.Fatal("deployment statuses were not as expected", statuses)
+	}
+
+	// Invalid repository
+	if err := updateGitHubDeployment(context.Background(), &codedeploy.DeploymentInfo{
+		DeploymentId: aws.String("d-NEW"),
+	}, &sourceRevision{Repository: "invalid"}, deploymentStateStart, "us-east-1"); err == nil {
+		t.Fatal("error should have occurred")
+	}
+}
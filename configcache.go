@@ -0,0 +1,114 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+)
+
+// loadedConfiguration is a loaded configuration with the token providers and pipeline routes
+// A load builds a new value and replaces the current one as a whole, a stored value is never changed
+// (requests keep using the value they read while a reload runs)
+type loadedConfiguration struct {
+	configuration
+	credentials credentialProvider            // Default
+	owners      map[string]credentialProvider // By owner (GITHUB_OWNER_TOKENS, lowercase)
+	routes      []*pipelineRoute              // First match wins
+}
+
+// configurationCache keeps the loaded configuration (and decrypted token) across warm invocations
+// The configuration is loaded once per container, or again after the TTL (CONFIG_CACHE_TTL) or an invalidation
+type configurationCache struct {
+	expires time.Time // Zero is no expiration
+	kmsSvc  kmsiface.KMSAPI
+	loaded  bool
+	lock    sync.Mutex
+}
+
+// sessionCache keeps the event sessions (region, account and role) so clients and assumed credentials are reused
+type sessionCache struct {
+	lock     sync.Mutex
+	sessions map[string]*session.Session
+}
+
+// accountCache keeps the account of the function (from the Lambda context, or STS on the first cross account check)
+type accountCache struct {
+	account string
+	lock    sync.Mutex
+	stsSvc  stsiface.STSAPI
+}
+
+// Local caches (warm invocations)
+var (
+	configCache          = &configurationCache{}
+	currentConfiguration atomic.Pointer[loadedConfiguration]
+	eventSessions        = &sessionCache{sessions: map[string]*session.Session{}}
+	functionAccount      = &accountCache{}
+)
+
+// getConfig will return the current configuration (empty if not loaded), safe to use during a reload
+func getConfig() *loadedConfiguration {
+	if loaded := currentConfiguration.Load(); loaded != nil {
+		return loaded
+	}
+	return &loadedConfiguration{}
+}
+
+// getConfiguration will return the cached configuration, or load it (envconfig and the token) if not loaded or expired
+// Failed loads are not cached
+func getConfiguration() error {
+	configCache.lock.Lock()
+	defer configCache.lock.Unlock()
+
+	// Use the cache
+	if configCache.loaded && (configCache.expires.IsZero() || time.Now().Before(configCache.expires)) {
+		return nil
+	}
+
+	// Load the configuration (reuse the KMS client), the current configuration is kept if the load fails
+	if configCache.kmsSvc == nil {
+		configCache.kmsSvc = kms.New(awsSession)
+	}
+	if err := loadConfiguration(configCache.kmsSvc); err != nil {
+		return err
+	}
+	configCache.loaded = true
+	configCache.expires = time.Time{}
+	if ttl := getConfig().ConfigCacheTTL; ttl > 0 {
+		configCache.expires = time.Now().Add(ttl)
+	}
+	return nil
+}
+
+// invalidateConfiguration will reload the configuration on the next use (IE: GitHub rejected the token)
+func invalidateConfiguration() {
+	configCache.lock.Lock()
+	defer configCache.lock.Unlock()
+	configCache.loaded = false
+}
+
+// resetConfiguration will drop the cached configuration, token, routes, account and clients (tests or a changed environment)
+func resetConfiguration() {
+	configCache.lock.Lock()
+	configCache.expires = time.Time{}
+	configCache.kmsSvc = nil
+	configCache.loaded = false
+	configCache.lock.Unlock()
+
+	eventSessions.lock.Lock()
+	eventSessions.sessions = map[string]*session.Session{}
+	eventSessions.lock.Unlock()
+
+	functionAccount.lock.Lock()
+	functionAccount.account = ""
+	functionAccount.stsSvc = nil
+	functionAccount.lock.Unlock()
+
+	currentConfiguration.Store(nil)
+	githubTokenCache = &cachedToken{}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+)
+
+// mockSTSClient is the account of the function for getFunctionAccount()
+type mockSTSClient struct {
+	stsiface.STSAPI
+	account string
+	calls   int
+}
+
+// GetCallerIdentity is a mock request for sts
+func (m *mockSTSClient) GetCallerIdentity(_ *sts.GetCallerIdentityInput) (*sts.GetCallerIdentityOutput, error) {
+	m.calls++
+	return &sts.GetCallerIdentityOutput{Account: aws.String(m.account)}, nil
+}
+
+// setFunctionAccountClient will use the mock STS client for the account of the function (reset on cleanup)
+func setFunctionAccountClient(t *testing.T, account string) *mockSTSClient {
+	mockSTS := &mockSTSClient{account: account}
+	functionAccount.lock.Lock()
+	functionAccount.account = ""
+	functionAccount.stsSvc = mockSTS
+	functionAccount.lock.Unlock()
+	t.Cleanup(func() {
+		functionAccount.lock.Lock()
+		functionAccount.account = ""
+		functionAccount.stsSvc = nil
+		functionAccount.lock.Unlock()
+	})
+	return mockSTS
+}
+
+// setConfig will replace the configuration with an updated copy for the test (restored on cleanup)
+func setConfig(t *testing.T, update func(c *loadedConfiguration)) {
+	previous := currentConfiguration.Load()
+	updated := *getConfig()
+	update(&updated)
+	currentConfiguration.Store(&updated)
+	t.Cleanup(func() {
+		currentConfiguration.Store(previous)
+	})
+}
+
+// TestGetConfiguration will test getConfiguration(), invalidateConfiguration() and the TTL
+func TestGetConfiguration(t *testing.T) {
+	setHTTPEnvironment(t, httpAuthSecret)
+
+	// Failed loads are not cached
+	_ = os.Setenv("APPLICATION_STAGE_NAME", "")
+	if err := getConfiguration(); err == nil {
+		t.Fatal("error should have occurred")
+	}
+	_ = os.Setenv("APPLICATION_STAGE_NAME", "testing")
+	if err := getConfiguration(); err != nil {
+		t.Fatal("error occurred", err.Error())
+	} else if getConfig().HTTPSharedSecret != "test-secret" {
+		t.Fatal("configuration was not loaded", getConfig().HTTPSharedSecret)
+	} else if !getConfig().AllSourceArtifacts {
+		t.Fatal("expected every source artifact by default")
+	}
+
+	// Cached (environment changes are not loaded)
+	_ = os.Setenv("HTTP_SHARED_SECRET", "rotated-secret")
+	if err := getConfiguration(); err != nil {
+		t.Fatal("error occurred", err.Error())
+	} else if getConfig().HTTPSharedSecret != "test-secret" {
+		t.Fatal("expected the cached configuration", getConfig().HTTPSharedSecret)
+	}
+
+	// Invalidated
+	invalidateConfiguration()
+	if err := getConfiguration(); err != nil {
+		t.Fatal("error occurred", err.Error())
+	} else if getConfig().HTTPSharedSecret != "rotated-secret" {
+		t.Fatal("expected the configuration to be loaded again", getConfig().HTTPSharedSecret)
+	}
+
+	// Expired
+	_ = os.Setenv("CONFIG_CACHE_TTL", "1m")
+	_ = os.Setenv("HTTP_SHARED_SECRET", "test-secret")
+	defer func() {
+		_ = os.Unsetenv("CONFIG_CACHE_TTL")
+	}()
+	invalidateConfiguration()
+	if err := getConfiguration(); err != nil {
+		t.Fatal("error occurred", err.Error())
+	} else if configCache.expires.IsZero() {
+		t.Fatal("expected the configuration to expire")
+	}
+	_ = os.Setenv("HTTP_SHARED_SECRET", "expired-secret")
+	configCache.expires = time.Now().Add(-time.Second)
+	if err := getConfiguration(); err != nil {
+		t.Fatal("error occurred", err.Error())
+	} else if getConfig().HTTPSharedSecret != "expired-secret" {
+		t.Fatal("expected the configuration to be loaded after the ttl", getConfig().HTTPSharedSecret)
+	}
+}
+
+// TestGetConfigurationReload will test reading the configuration while it is loaded again
+func TestGetConfigurationReload(t *testing.T) {
+	setHTTPEnvironment(t, httpAuthSecret)
+	if err := getConfiguration(); err != nil {
+		t.Fatal("error occurred", err.Error())
+	}
+
+	done := make(chan struct{})
+	var readers sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				if cfg := getConfig(); cfg.GithubAccessToken != "1234567" || cfg.Stage != stageTesting || cfg.credentials == nil {
+					t.Error("expected a complete configuration during the reload", cfg.GithubAccessToken, cfg.Stage)
+					return
+				} else if token, err := getGithubToken(context.Background(), false); err != nil || token != "1234567" {
+					t.Error("expected the token during the reload", token, err)
+					return
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 50; i++ {
+		invalidateConfiguration()
+		if err := getConfiguration(); err != nil {
+			t.Error("error occurred", err.Error())
+			break
+		}
+	}
+	close(done)
+	readers.Wait()
+}
+
+// TestGithubRequestInvalidate will test githubRequest() invalidating the configuration on a 401
+func TestGithubRequestInvalidate(t *testing.T) {
+	setHTTPEnvironment(t, httpAuthSecret)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	_ = os.Setenv("GITHUB_API_URL", server.URL)
+	defer func() {
+		_ = os.Unsetenv("GITHUB_API_URL")
+	}()
+	if err := getConfiguration(); err != nil {
+		t.Fatal("error occurred", err.Error())
+	} else if !configCache.loaded {
+		t.Fatal("expected the configuration to be cached")
+	}
+
+	if err := githubRequest(context.Background(), http.MethodGet, "/user", nil, nil, http.StatusOK); err == nil {
+		t.Fatal("error should have occurred")
+	} else if configCache.loaded {
+		t.Fatal("expected the configuration to be invalidated")
+	}
+}
+
+// TestGetEventSessionCache will test getEventSession() reusing the sessions
+func TestGetEventSessionCache(t *testing.T) {
+	setHTTPEnvironment(t, httpAuthSecret)
+	mockSTS := setFunctionAccountClient(t, "123456789012")
+
+	if getEventSession(event{Region: "eu-west-1"}) != getEventSession(event{Account: "210987654321", Region: "eu-west-1"}) {
+		t.Fatal("expected the same session for the region without a cross account role")
+	} else if getEventSession(event{Region: "eu-west-1"}) == getEventSession(event{Region: "us-west-2"}) {
+		t.Fatal("expected a session per region")
+	}
+
+	setConfig(t, func(c *loadedConfiguration) {
+		c.CrossAccountRoleName = "codepipeline-to-github"
+	})
+	if getEventSession(event{Account: "210987654321", Region: "eu-west-1"}) == getEventSession(event{Account: "345678901234", Region: "eu-west-1"}) {
+		t.Fatal("expected a session per account")
+	}
+
+	// Same account as the function (no cross account role, the account is looked up once)
+	sameAccount := getEventSession(event{Account: "123456789012", Region: "eu-west-1"})
+	if sameAccount != getEventSession(event{Region: "eu-west-1"}) {
+		t.Fatal("expected the session of the function for its own account")
+	} else if sameAccount.Config.Credentials != awsSession.Config.Credentials {
+		t.Fatal("expected the credentials of the function for its own account")
+	} else if mockSTS.calls != 1 {
+		t.Fatal("expected the account to be cached", mockSTS.calls)
+	}
+
+	// Account of the Lambda context (no STS lookup)
+	mockSTS = setFunctionAccountClient(t, "123456789012")
+	setFunctionAccount(lambdacontext.NewContext(context.Background(), &lambdacontext.LambdaContext{
+		InvokedFunctionArn: "arn:aws:lambda:eu-west-1:210987654321:function:codepipeline-to-github",
+	}))
+	if getEventSession(event{Account: "210987654321", Region: "eu-west-1"}) != getEventSession(event{Region: "eu-west-1"}) {
+		t.Fatal("expected the session of the function for the account of the Lambda context")
+	} else if mockSTS.calls != 0 {
+		t.Fatal("expected no STS lookup with the Lambda context", mockSTS.calls)
+	}
+}
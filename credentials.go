@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+)
+
+// Credential providers (GITHUB_TOKEN_PROVIDER)
+const (
+	credentialProviderEnv            = "env"
+	credentialProviderKMS            = "kms"
+	credentialProviderSecretsManager = "secretsmanager"
+	credentialProviderSSM            = "ssm"
+	defaultTokenTTL                  = 5 * time.Minute
+)
+
+// credentialProvider provides the GitHub token (environment, KMS, SSM Parameter Store or Secrets Manager)
+type credentialProvider interface {
+	Name() string
+	Token(refresh bool) (string, error) // Refresh skips the cache (IE: after a 401 from GitHub)
+}
+
+// getCredentialProvider will return the provider of the GitHub token from the configuration
+// Without GITHUB_TOKEN_PROVIDER: Secrets Manager, SSM, the plain env (testing stage) or the KMS encrypted env
+func getCredentialProvider(cfg *configuration, kmsSvc kmsiface.KMSAPI) (credentialProvider, error) {
+	name := cfg.GithubTokenProvider
+	if len(name) == 0 {
+		switch {
+		case len(cfg.GithubTokenSecretARN) > 0:
+			name = credentialProviderSecretsManager
+		case len(cfg.GithubTokenParameter) > 0:
+			name = credentialProviderSSM
+		case cfg.Stage == stageTesting:
+			name = credentialProviderEnv
+		default:
+			name = credentialProviderKMS
+		}
+	}
+
+	switch name {
+	case credentialProviderEnv, credentialProviderKMS:
+		if len(cfg.GithubAccessToken) == 0 {
+			return nil, errors.New("required key GITHUB_ACCESS_TOKEN missing value")
+		} else if name == credentialProviderEnv {
+			return &envCredentials{token: cfg.GithubAccessToken}, nil
+		}
+		return &kmsCredentials{config: cfg, encryptedToken: cfg.GithubAccessToken, kmsSvc: kmsSvc}, nil
+	case credentialProviderSSM:
+		if len(cfg.GithubTokenParameter) == 0 {
+			return nil, errors.New("required key GITHUB_TOKEN_PARAMETER missing value")
+		}
+		return &ssmCredentials{parameterName: cfg.GithubTokenParameter, ttl: cfg.GithubTokenTTL}, nil
+	case credentialProviderSecretsManager:
+		if len(cfg.GithubTokenSecretARN) == 0 {
+			return nil, errors.New("required key GITHUB_TOKEN_SECRET_ARN missing value")
+		}
+		return &secretsManagerCredentials{
+			key: cfg.GithubTokenSecretKey, secretID: cfg.GithubTokenSecretARN, ttl: cfg.GithubTokenTTL,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown GITHUB_TOKEN_PROVIDER: %s", name)
+	}
+}
+
+// credentialsKey is the context key of the provider for a request (IE: the credential of a pipeline route)
+type credentialsKey struct{}
+
+// withCredentials will return a context using the provider for the GitHub requests (nil keeps the configured provider)
+func withCredentials(ctx context.Context, provider credentialProvider) context.Context {
+	if provider == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, credentialsKey{}, provider)
+}
+
+// getCredentials will return the provider of the request (context) or the provider chosen by loadConfiguration
+func getCredentials(ctx context.Context) credentialProvider {
+	if provider, ok := ctx.Value(credentialsKey{}).(credentialProvider); ok {
+		return provider
+	}
+	return getConfig().credentials
+}
+
+// getOwnerCredentials will return the providers of the owners (GITHUB_OWNER_TOKENS), the tokens are decrypted on first use
+// The tokens are KMS encrypted, or plain if the default provider is env (testing stage)
+func getOwnerCredentials(cfg *configuration, kmsSvc kmsiface.KMSAPI, defaultProvider credentialProvider) (map[string]credentialProvider, error) {
+	if len(cfg.GithubOwnerTokens) == 0 {
+		return nil, nil
+	}
+	providers := make(map[string]credentialProvider, len(cfg.GithubOwnerTokens))
+	for owner, token := range cfg.GithubOwnerTokens {
+		owner, token = strings.ToLower(strings.TrimSpace(owner)), strings.TrimSpace(token)
+		if len(owner) == 0 || len(token) == 0 {
+			return nil, errors.New("invalid GITHUB_OWNER_TOKENS: expected owner:token")
+		} else if defaultProvider != nil && defaultProvider.Name() == credentialProviderEnv {
+			providers[owner] = &envCredentials{token: token}
+		} else {
+			providers[owner] = &kmsCredentials{config: cfg, encryptedToken: token, kmsSvc: kmsSvc}
+		}
+	}
+	return providers, nil
+}
+
+// withOwnerCredentials will return a context using the provider of the owner (GITHUB_OWNER_TOKENS)
+// The provider of the context (IE: the credential of a pipeline route) is kept, owners without a token use the default
+func withOwnerCredentials(ctx context.Context, owner string) context.Context {
+	if _, ok := ctx.Value(credentialsKey{}).(credentialProvider); ok {
+		return ctx
+	}
+	return withCredentials(ctx, getConfig().owners[strings.ToLower(owner)])
+}
+
+// getGithubToken will return the GitHub token from the provider (the configured token if none was loaded)
+func getGithubToken(ctx context.Context, refresh bool) (string, error) {
+	provider := getCredentials(ctx)
+	if provider == nil {
+		return getConfig().GithubAccessToken, nil
+	}
+	return provider.Token(refresh)
+}
+
+// envCredentials is the plain token from GITHUB_ACCESS_TOKEN (configuration) or GITHUB_OWNER_TOKENS
+type envCredentials struct {
+	token string // Defaults to GITHUB_ACCESS_TOKEN
+}
+
+// Name will return the provider name
+func (c *envCredentials) Name() string {
+	return credentialProviderEnv
+}
+
+// Token will return the token
+func (c *envCredentials) Token(_ bool) (string, error) {
+	if len(c.token) > 0 {
+		return c.token, nil
+	}
+	return getConfig().GithubAccessToken, nil
+}
+
+// kmsCredentials is the KMS encrypted token from GITHUB_ACCESS_TOKEN (decrypted once)
+type kmsCredentials struct {
+	config         *configuration // Encryption context (nil is the current configuration)
+	encryptedToken string
+	kmsSvc         kmsiface.KMSAPI
+	lock           sync.Mutex
+	token          string
+}
+
+// Name will return the provider name
+func (c *kmsCredentials) Name() string {
+	return credentialProviderKMS
+}
+
+// Token will return the decrypted token (the same ciphertext is never decrypted twice)
+func (c *kmsCredentials) Token(_ bool) (string, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if len(c.token) == 0 {
+		cfg := c.config
+		if cfg == nil {
+			cfg = &getConfig().configuration
+		}
+		token, err := decryptConfigString(cfg, c.kmsSvc, c.encryptedToken)
+		if err != nil {
+			return "", err
+		}
+		c.token = token
+	}
+	return c.token, nil
+}
+
+// cachedToken is a token read at runtime (Secrets Manager or SSM), kept across warm invocations
+// The token is cached for the TTL (GITHUB_TOKEN_TTL) and refreshed early after a 401 from GitHub (rotations)
+type cachedToken struct {
+	expires time.Time
+	lock    sync.Mutex
+	source  string // Secret or parameter of the token
+	token   string
+}
+
+// githubTokenCache is the cached token of the runtime providers
+var githubTokenCache = &cachedToken{}
+
+// getTokenCache will return the cache of a provider (the shared cache if the provider has none)
+func getTokenCache(cache *cachedToken) *cachedToken {
+	if cache == nil {
+		return githubTokenCache
+	}
+	return cache
+}
+
+// get will return the cached token of the source, or read it if the cache expired (or refresh is set)
+// The TTL is the one of the provider (GITHUB_TOKEN_TTL of its configuration), zero is the default TTL
+func (c *cachedToken) get(source string, ttl time.Duration, refresh bool, read func() (string, error)) (string, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	// Use the cache (same source and not expired)
+	if !refresh && len(c.token) > 0 && c.source == source && time.Now().Before(c.expires) {
+		return c.token, nil
+	}
+
+	// Read the token
+	token, err := read()
+	if err != nil {
+		return "", err
+	}
+
+	if ttl <= 0 {
+		ttl = defaultTokenTTL
+	}
+	c.expires = time.Now().Add(ttl)
+	c.source = source
+	c.token = token
+	return token, nil
+}
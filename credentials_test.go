@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestGetCredentialProvider will test getCredentialProvider()
+func TestGetCredentialProvider(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		name             string
+		config           configuration
+		expectedProvider string
+		expectedError    bool
+	}{
+		{"kms by default", configuration{GithubAccessToken: "encrypted", Stage: "production"}, credentialProviderKMS, false},
+		{"env on testing stage", configuration{GithubAccessToken: "plain", Stage: stageTesting}, credentialProviderEnv, false},
+		{"secrets manager", configuration{GithubTokenSecretARN: "arn:secret", Stage: "production"}, credentialProviderSecretsManager, false},
+		{"ssm", configuration{GithubTokenParameter: "/app/github_token", Stage: "production"}, credentialProviderSSM, false},
+		{"explicit env", configuration{GithubAccessToken: "plain", GithubTokenProvider: "env", Stage: "production"}, credentialProviderEnv, false},
+		{"explicit kms on testing stage", configuration{GithubAccessToken: "encrypted", GithubTokenProvider: "kms", Stage: stageTesting}, credentialProviderKMS, false},
+		{"explicit ssm over a secret", configuration{GithubTokenParameter: "/app/github_token", GithubTokenProvider: "ssm", GithubTokenSecretARN: "arn:secret"}, credentialProviderSSM, false},
+		{"missing token", configuration{Stage: "production"}, "", true},
+		{"ssm without parameter", configuration{GithubAccessToken: "plain", GithubTokenProvider: "ssm"}, "", true},
+		{"secrets manager without arn", configuration{GithubAccessToken: "plain", GithubTokenProvider: "secretsmanager"}, "", true},
+		{"unknown provider", configuration{GithubAccessToken: "plain", GithubTokenProvider: "vault"}, "", true},
+	}
+
+	for _, test := range tests {
+		provider, err := getCredentialProvider(&test.config, &mockKmsClient{})
+		if err == nil && test.expectedError {
+			t.Errorf("%s Failed: [%s] expected to throw an error, but no error", t.Name(), test.name)
+		} else if err != nil && !test.expectedError {
+			t.Errorf("%s Failed: [%s] error occurred [%s]", t.Name(), test.name, err.Error())
+		} else if err == nil && provider.Name() != test.expectedProvider {
+			t.Errorf("%s Failed: [%s] expected [%s] got [%s]", t.Name(), test.name, test.expectedProvider, provider.Name())
+		}
+	}
+	// TTL of the runtime providers (GITHUB_TOKEN_TTL of the configuration being loaded)
+	cfg := &configuration{GithubTokenParameter: "/app/github_token", GithubTokenSecretARN: "arn:secret", GithubTokenTTL: time.Hour}
+	if provider, err := getCredentialProvider(cfg, &mockKmsClient{}); err != nil || provider.(*secretsManagerCredentials).ttl != time.Hour {
+		t.Fatal("expected the ttl of the configuration", provider, err)
+	}
+	cfg.GithubTokenProvider = credentialProviderSSM
+	if provider, err := getCredentialProvider(cfg, &mockKmsClient{}); err != nil || provider.(*ssmCredentials).ttl != time.Hour {
+		t.Fatal("expected the ttl of the configuration", provider, err)
+	}
+}
+
+// TestKMSCredentials will test the kms provider (decrypted once)
+func TestKMSCredentials(t *testing.T) {
+	t.Parallel()
+
+	provider := &kmsCredentials{encryptedToken: "ZW5jcnlwdGVkLXRva2Vu", kmsSvc: &mockKmsClient{}}
+	for i := 0; i < 2; i++ {
+		if token, err := provider.Token(false); err != nil || token != "some-encrypted-text" {
+			t.Fatal("expected the decrypted token", token, err)
+		}
+	}
+
+	if _, err := (&kmsCredentials{encryptedToken: "not base64!", kmsSvc: &mockKmsClient{}}).Token(false); err == nil {
+		t.Fatal("error should have occurred")
+	}
+}
+
+// TestGetOwnerCredentials will test getOwnerCredentials()
+func TestGetOwnerCredentials(t *testing.T) {
+	t.Parallel()
+
+	// No owner tokens
+	if providers, err := getOwnerCredentials(&configuration{}, &mockKmsClient{}, &kmsCredentials{}); err != nil || providers != nil {
+		t.Fatal("expected no providers", providers, err)
+	}
+
+	// Encrypted (lowercase owners)
+	cfg := &configuration{GithubOwnerTokens: map[string]string{"MrZ1836": "ZW5jcnlwdGVk", "other-org": "b3RoZXI="}}
+	providers, err := getOwnerCredentials(cfg, &mockKmsClient{}, &kmsCredentials{})
+	if err != nil {
+		t.Fatal("error occurred", err.Error())
+	} else if len(providers) != 2 || providers["mrz1836"] == nil || providers["mrz1836"].Name() != credentialProviderKMS {
+		t.Fatal("expected a kms provider per owner", providers)
+	}
+
+	// Plain with the env provider (testing stage)
+	if providers, err = getOwnerCredentials(cfg, &mockKmsClient{}, &envCredentials{}); err != nil {
+		t.Fatal("error occurred", err.Error())
+	} else if token, _ := providers["other-org"].Token(false); token != "b3RoZXI=" {
+		t.Fatal("expected the plain token", token)
+	}
+
+	// Missing token
+	cfg = &configuration{GithubOwnerTokens: map[string]string{"mrz1836": " "}}
+	if _, err = getOwnerCredentials(cfg, &mockKmsClient{}, &kmsCredentials{}); err == nil {
+		t.Fatal("error should have occurred")
+	}
+}
+
+// TestOwnerCredentials will test the token used per owner (decrypted once on first use)
+func TestOwnerCredentials(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var expected string
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/repos/MrZ1836/"):
+			expected = "token context-text"
+		default:
+			expected = "token default-token"
+		}
+		if r.Header.Get("Authorization") != expected {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	mockKms := &mockKmsContextClient{}
+	cfg := configuration{
+		GithubAPIURL:      server.URL,
+		GithubOwnerTokens: map[string]string{"mrz1836": "ZW5jcnlwdGVk"},
+	}
+	owners, err := getOwnerCredentials(&cfg, mockKms, &kmsCredentials{})
+	if err != nil {
+		t.Fatal("error occurred", err.Error())
+	}
+	setConfig(t, func(c *loadedConfiguration) {
+		*c = loadedConfiguration{
+			configuration: cfg,
+			credentials:   &envCredentials{token: "default-token"},
+			owners:        owners,
+		}
+	})
+
+	// Decrypted on first use
+	if mockKms.calls != 0 {
+		t.Fatal("expected the token to be decrypted lazily", mockKms.calls)
+	}
+	for i := 0; i < 2; i++ {
+		if err = postCommitStatus(context.Background(), "MrZ1836", "repo", "sha", &payload{}); err != nil {
+			t.Fatal("error occurred", err.Error())
+		}
+	}
+	if mockKms.calls != 1 {
+		t.Fatal("expected the token to be decrypted once", mockKms.calls)
+	}
+
+	// Default token
+	if err = postCommitStatus(context.Background(), "other-org", "repo", "sha", &payload{}); err != nil {
+		t.Fatal("error occurred", err.Error())
+	}
+
+	// Provider of the context (route) takes precedence
+	ctx := withCredentials(context.Background(), &envCredentials{token: "default-token"})
+	if err = postCommitStatus(ctx, "other-org", "repo", "sha", &payload{}); err != nil {
+		t.Fatal("error occurred", err.Error())
+	} else if err = postCommitStatus(ctx, "MrZ1836", "repo", "sha", &payload{}); err == nil {
+		t.Fatal("expected the token of the context to be used")
+	}
+}
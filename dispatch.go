@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Event kinds (EVENT_KINDS) and the detail-types of the CodePipeline events
+const (
+	detailTypeActionStateChange   = "CodePipeline Action Execution State Change"
+	detailTypePipelineStateChange = "CodePipeline Pipeline Execution State Change"
+	detailTypeStageStateChange    = "CodePipeline Stage Execution State Change"
+	eventKindAction               = "action"
+	eventKindBuild                = "build"
+	eventKindDeployment           = "deployment"
+	eventKindPipeline             = "pipeline"
+	eventKindStage                = "stage"
+)
+
+// errSkipEvent is returned by a validation when the event should be ignored (not an error)
+var errSkipEvent = errors.New("skip event")
+
+// eventHandler is the handler for a kind of event
+type eventHandler struct {
+	kind     string
+	process  func(ev event) error
+	validate func(ev event) error // Runs before loading the configuration
+}
+
+// eventHandlers are the handlers by detail-type (events without a detail-type are pipeline events)
+var eventHandlers = map[string]*eventHandler{
+	"":                              {kind: eventKindPipeline, process: processPipelineEvent, validate: validateExecutionEvent},
+	detailTypePipelineStateChange:   {kind: eventKindPipeline, process: processPipelineEvent, validate: validateExecutionEvent},
+	detailTypeStageStateChange:      {kind: eventKindStage, process: processStageEvent, validate: validateStageEvent},
+	detailTypeActionStateChange:     {kind: eventKindAction, process: processActionEvent, validate: validateActionEvent},
+	detailTypeBuildStateChange:      {kind: eventKindBuild, process: processBuildEvent, validate: validateBuildEvent},
+	detailTypeDeploymentStateChange: {kind: eventKindDeployment, process: processDeploymentEvent, validate: validateDeploymentEvent},
+	detailTypeScheduledEvent:        {kind: eventKindSchedule, process: processScheduledEvent, validate: validateScheduledEvent},
+	detailTypeStatusUpdate:          {kind: eventKindStatus, process: processStatusEvent, validate: validateStatusEvent},
+}
+
+// getEventHandler will return the handler of the event (manual approval actions have their own handler)
+func getEventHandler(ev event) (*eventHandler, bool) {
+	if isApprovalEvent(ev) {
+		return approvalHandler, true
+	}
+	handler, ok := eventHandlers[ev.DetailType]
+	return handler, ok
+}
+
+// getExecutionKind will return the kind of a pipeline execution event (pipeline, stage, action or approval)
+func getExecutionKind(ev event) string {
+	switch {
+	case isApprovalEvent(ev):
+		return eventKindApproval
+	case ev.DetailType == detailTypeActionStateChange:
+		return eventKindAction
+	case ev.DetailType == detailTypeStageStateChange:
+		return eventKindStage
+	default:
+		return eventKindPipeline
+	}
+}
+
+// isEventKindEnabled will return true if the kind of event is enabled (EVENT_KINDS or the kinds of the pipeline route)
+func isEventKindEnabled(kind, pipelineName string) bool {
+	kinds := getConfig().EventKinds
+	if route := getPipelineRoute(pipelineName); route != nil && len(route.EventKinds) > 0 {
+		kinds = route.EventKinds
+	}
+	for _, enabled := range kinds {
+		if strings.TrimSpace(enabled) == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// isKnownEventKind will return true if the kind of event is supported
+func isKnownEventKind(kind string) bool {
+	switch kind {
+	case eventKindAction, eventKindApproval, eventKindBuild, eventKindDeployment,
+		eventKindPipeline, eventKindSchedule, eventKindStage, eventKindStatus:
+		return true
+	default:
+		return false
+	}
+}
+
+// validateStageEvent will check the required parameters of a stage execution event
+func validateStageEvent(ev event) error {
+	if len(ev.Detail.Stage) == 0 {
+		return permanent(errors.New("missing event param stage"))
+	}
+	return validateExecutionEvent(ev)
+}
+
+// processStageEvent will post the status of the stage (own context per stage) for every source revision
+func processStageEvent(ev event) error {
+	return processExecutionEvent(ev, func(update *statusUpdate) {
+		update.Context = githubContext + "/" + ev.Detail.Stage
+		update.Description = getEventDescription("Stage "+ev.Detail.Stage, ev.Detail.State)
+		update.State = getEventStatus(ev.Detail.State)
+	})
+}
+
+// validateActionEvent will check the required parameters of an action execution event
+func validateActionEvent(ev event) error {
+	if len(ev.Detail.Action) == 0 {
+		return permanent(errors.New("missing event param action"))
+	}
+	return validateStageEvent(ev)
+}
+
+// processActionEvent will post the status of the action (own context per action) for every source revision
+func processActionEvent(ev event) error {
+	return processExecutionEvent(ev, func(update *statusUpdate) {
+		update.Context = githubContext + "/" + ev.Detail.Stage + "/" + ev.Detail.Action
+		update.Description = getEventDescription("Action "+ev.Detail.Action, ev.Detail.State)
+		update.State = getEventStatus(ev.Detail.State)
+	})
+}
+
+// getEventStatus will return the GitHub status based on the state of a stage or action event
+func getEventStatus(state string) string {
+	switch state {
+	case "STARTED", "RESUMED", "STOPPING":
+		return "pending"
+	case "SUCCEEDED":
+		return "success"
+	case "FAILED":
+		return "failure"
+	default:
+		return "error"
+	}
+}
+
+// getEventDescription will return the status description of a stage or action event (IE: Stage Build succeeded)
+func getEventDescription(name, state string) string {
+	description := fmt.Sprintf("%s %s", name, strings.ToLower(state))
+	if len(description) > 140 {
+		description = description[:137] + "..."
+	}
+	return description
+}
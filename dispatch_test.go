@@ -0,0 +1,110 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// TestProcessEventDispatch will test the routing of ProcessEvent() by detail-type
+func TestProcessEventDispatch(t *testing.T) {
+
+	// Create a new AWS session
+	if awsSession == nil {
+		awsSession = session.Must(session.NewSession(&aws.Config{
+			Region: aws.String("us-east-1"),
+		}))
+	}
+
+	t.Run("unsupported detail-type is ignored", func(t *testing.T) {
+		if err := ProcessEvent(event{
+			Detail:     &detail{},
+			DetailType: "CodePipeline Pipeline Execution Something Else",
+		}); err != nil {
+			t.Fatal("error occurred", err.Error())
+		}
+	})
+
+	t.Run("missing param stage", func(t *testing.T) {
+		if err := ProcessEvent(event{
+			Detail:     &detail{ExecutionID: "12345", Pipeline: "my-pipeline"},
+			DetailType: detailTypeStageStateChange,
+		}); err == nil || !isPermanentError(err) {
+			t.Fatal("expected a permanent error", err)
+		}
+	})
+
+	t.Run("missing param action", func(t *testing.T) {
+		if err := ProcessEvent(event{
+			Detail:     &detail{ExecutionID: "12345", Pipeline: "my-pipeline", Stage: "Build"},
+			DetailType: detailTypeActionStateChange,
+		}); err == nil || !isPermanentError(err) {
+			t.Fatal("expected a permanent error", err)
+		}
+	})
+
+	t.Run("disabled event kinds are ignored", func(t *testing.T) {
+		_ = os.Setenv("GITHUB_ACCESS_TOKEN", "1234567")
+		_ = os.Setenv("AWS_REGION", "us-east-1")
+		_ = os.Setenv("APPLICATION_STAGE_NAME", "testing")
+		_ = os.Setenv("EVENT_KINDS", "pipeline")
+		defer func() {
+			_ = os.Unsetenv("EVENT_KINDS")
+		}()
+		for _, detailType := range []string{detailTypeStageStateChange, detailTypeActionStateChange} {
+			if err := ProcessEvent(event{
+				Detail: &detail{
+					Action:      "Deploy",
+					ExecutionID: "12345",
+					Pipeline:    "my-pipeline",
+					Stage:       "Build",
+					State:       "SUCCEEDED",
+				},
+				DetailType: detailType,
+			}); err != nil {
+				t.Fatalf("%s Failed: [%s] error occurred [%s]", t.Name(), detailType, err.Error())
+			}
+		}
+	})
+}
+
+// TestIsEventKindEnabled will test isEventKindEnabled()
+func TestIsEventKindEnabled(t *testing.T) {
+
+	setConfig(t, func(c *loadedConfiguration) {
+		c.EventKinds = []string{"pipeline", " build"}
+	})
+
+	if !isEventKindEnabled(eventKindPipeline, "") || !isEventKindEnabled(eventKindBuild, "") {
+		t.Fatal("expected pipeline and build to be enabled")
+	} else if isEventKindEnabled(eventKindStage, "") {
+		t.Fatal("expected stage to be disabled")
+	}
+}
+
+// TestGetEventStatus will test getEventStatus() and getEventDescription()
+func TestGetEventStatus(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		state               string
+		expectedStatus      string
+		expectedDescription string
+	}{
+		{"STARTED", "pending", "Stage Build started"},
+		{"RESUMED", "pending", "Stage Build resumed"},
+		{"SUCCEEDED", "success", "Stage Build succeeded"},
+		{"FAILED", "failure", "Stage Build failed"},
+		{"CANCELED", "error", "Stage Build canceled"},
+	}
+
+	for _, test := range tests {
+		if status := getEventStatus(test.state); status != test.expectedStatus {
+			t.Errorf("%s Failed: [%s] expected status [%s] got [%s]", t.Name(), test.state, test.expectedStatus, status)
+		} else if description := getEventDescription("Stage Build", test.state); description != test.expectedDescription {
+			t.Errorf("%s Failed: [%s] expected description [%s] got [%s]", t.Name(), test.state, test.expectedDescription, description)
+		}
+	}
+}
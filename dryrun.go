@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+)
+
+// Dry-run defaults
+const (
+	redactedValue = "[REDACTED]" // Replaces the GitHub token and the path of the webhook urls
+	statusDryRun  = "dry-run"
+)
+
+// dryRunRequest is a http request that would have been sent (DRY_RUN)
+type dryRunRequest struct {
+	Body    json.RawMessage   `json:"body,omitempty"`
+	Headers map[string]string `json:"headers"`
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+}
+
+// dryRunRecorder keeps the requests of the current dry-run (one at a time)
+type dryRunRecorder struct {
+	lock     sync.Mutex
+	requests []*dryRunRequest
+	run      sync.Mutex
+}
+
+// dryRunRequests is the recorder of the dry-run mode
+var dryRunRequests = &dryRunRecorder{}
+
+// runDryRun will run the processing and return the requests that would have been sent
+// Runs are serialized so the requests belong to this run (the standalone server handles requests concurrently)
+func runDryRun(process func() error) ([]*dryRunRequest, error) {
+	dryRunRequests.run.Lock()
+	defer dryRunRequests.run.Unlock()
+
+	dryRunRequests.lock.Lock()
+	dryRunRequests.requests = nil
+	dryRunRequests.lock.Unlock()
+
+	err := process()
+
+	dryRunRequests.lock.Lock()
+	defer dryRunRequests.lock.Unlock()
+	requests := dryRunRequests.requests
+	dryRunRequests.requests = nil
+	return requests, err
+}
+
+// recordDryRunRequest will log (and keep) the request instead of sending it, the Authorization header is redacted
+// The path and query of a url that is a credential (Slack and webhook urls) are redacted, only the scheme and host are kept
+func recordDryRunRequest(req *http.Request, body []byte, redactURL bool) {
+	request := &dryRunRequest{
+		Headers: make(map[string]string, len(req.Header)),
+		Method:  req.Method,
+		URL:     req.URL.String(),
+	}
+	if redactURL {
+		request.URL = req.URL.Scheme + "://" + req.URL.Host + "/" + redactedValue
+	}
+	for key := range req.Header {
+		request.Headers[key] = req.Header.Get(key)
+	}
+	if _, ok := request.Headers["Authorization"]; ok {
+		request.Headers["Authorization"] = redactedValue
+	}
+	if len(body) > 0 && json.Valid(body) {
+		request.Body = body
+	}
+
+	// Log the request (JSON)
+	data, _ := json.Marshal(request)
+	log.Printf("dry-run: %s", string(data))
+
+	dryRunRequests.lock.Lock()
+	dryRunRequests.requests = append(dryRunRequests.requests, request)
+	dryRunRequests.lock.Unlock()
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestRunDryRun will test runDryRun() and recordDryRunRequest() (nothing is sent, the token is redacted)
+func TestRunDryRun(t *testing.T) {
+
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	setConfig(t, func(c *loadedConfiguration) {
+		*c = loadedConfiguration{
+			configuration: configuration{DryRun: true, GithubAPIURL: server.URL},
+			credentials:   &envCredentials{token: "secret-token"},
+		}
+	})
+
+	recorded, err := runDryRun(func() error {
+		if err := postCommitStatus(context.Background(), "owner", "repo", "sha", &payload{Context: githubContext, State: "success"}); err != nil {
+			return err
+		}
+		if err := postJSON(context.Background(), server.URL+"/hook?key=secret", map[string]string{"text": "hello"}); err != nil {
+			return err
+		}
+		slack := &slackPublisher{webhookURL: "https://hooks.slack.com/services/T000/B000/XXXX"}
+		return slack.Publish(context.Background(), &statusUpdate{Commit: "25c0c3e61c4db2c2cde8b163b3ad096875c1ce08", State: "success"})
+	})
+	if err != nil {
+		t.Fatal("error occurred", err.Error())
+	} else if requests != 0 {
+		t.Fatal("expected no requests to be sent", requests)
+	} else if len(recorded) != 3 {
+		t.Fatal("expected the requests to be recorded", len(recorded))
+	}
+
+	// GitHub request
+	request := recorded[0]
+	var status payload
+	if request.Method != http.MethodPost || request.URL != server.URL+"/repos/owner/repo/statuses/sha" {
+		t.Fatal("request was not as expected", request.Method, request.URL)
+	} else if request.Headers["Authorization"] != redactedValue {
+		t.Fatal("expected the token to be redacted", request.Headers["Authorization"])
+	} else if err = json.Unmarshal(request.Body, &status); err != nil || status.Context != githubContext || status.State != "success" {
+		t.Fatal("body was not as expected", string(request.Body), err)
+	}
+
+	// Webhook and Slack requests (no authorization, the url is redacted)
+	if _, ok := recorded[1].Headers["Authorization"]; ok || recorded[1].URL != server.URL+"/"+redactedValue {
+		t.Fatal("webhook request was not as expected", recorded[1])
+	} else if recorded[2].URL != "https://hooks.slack.com/"+redactedValue {
+		t.Fatal("expected the slack url to be redacted", recorded[2].URL)
+	}
+
+	// Each run only returns its own requests
+	if recorded, _ = runDryRun(func() error { return nil }); len(recorded) != 0 {
+		t.Fatal("expected no requests", len(recorded))
+	}
+}
+
+// TestStatusHandlerDryRun will test the requests returned by statusHandler() and handleRequest() on a dry-run
+func TestStatusHandlerDryRun(t *testing.T) {
+	setHTTPEnvironment(t, httpAuthSecret)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("expected no requests to GitHub", r.URL.Path)
+	}))
+	defer server.Close()
+
+	_ = os.Setenv("DRY_RUN", "true")
+	_ = os.Setenv("GITHUB_API_URL", server.URL)
+	_ = os.Setenv("PUBLISHERS", publisherGitHub)
+	defer func() {
+		_ = os.Unsetenv("DRY_RUN")
+		_ = os.Unsetenv("GITHUB_API_URL")
+		_ = os.Unsetenv("PUBLISHERS")
+	}()
+
+	body := getStatusEventBody(t, newStatusEventDetail())
+
+	// Function URL, API Gateway or the standalone server
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(string(body)))
+	r.Header.Set("Authorization", "Bearer test-secret")
+	w := httptest.NewRecorder()
+	statusHandler(w, r)
+	var response httpResponse
+	if w.Code != http.StatusOK {
+		t.Fatal("unexpected status", w.Code, w.Body.String())
+	} else if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal("error occurred", err.Error())
+	} else if response.Status != statusDryRun || len(response.Requests) != 3 {
+		t.Fatal("expected the requests of the status and stages", response.Status, len(response.Requests))
+	} else if !strings.HasSuffix(response.Requests[0].URL, "/repos/mrz1836/codepipeline-to-github/statuses/25c0c3e61c4db2c2cde8b163b3ad096875c1ce08") {
+		t.Fatal("request url was not as expected", response.Requests[0].URL)
+	} else if strings.Contains(w.Body.String(), "1234567") {
+		t.Fatal("expected the token to be redacted", w.Body.String())
+	}
+
+	// Direct invocation
+	result, err := handleRequest(context.Background(), body)
+	if err != nil {
+		t.Fatal("error occurred", err.Error())
+	} else if invocation, ok := result.(*httpResponse); !ok || len(invocation.Requests) != 3 {
+		t.Fatal("expected the requests to be returned", result)
+	}
+}
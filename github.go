@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// GitHub defaults
+const (
+	githubContext = "continuous-integration/codepipeline"
+	githubHost    = "github.com"
+)
+
+// isGitHubURL will return true if the revision url points to a GitHub commit
+// (GitHub source action or a CodeStar connection redirect)
+func isGitHubURL(revisionURL *url.URL) bool {
+	if revisionURL == nil {
+		return false
+	} else if len(revisionURL.Query().Get("FullRepositoryId")) > 0 {
+		return true
+	}
+	return strings.TrimPrefix(revisionURL.Host, "www.") == githubHost
+}
+
+// getGitHubRepository will return the owner and repository name from a revision url
+func getGitHubRepository(revisionURL *url.URL) (owner, repo string, err error) {
+
+	// CodeStar connections use a redirect url with the full repository id
+	path := revisionURL.Path
+	if fullRepositoryID := revisionURL.Query().Get("FullRepositoryId"); len(fullRepositoryID) > 0 {
+		path = "/" + fullRepositoryID
+	}
+
+	// Break apart the components
+	parts := strings.Split(path, "/")
+	if len(parts) < 3 || len(parts[1]) == 0 || len(parts[2]) == 0 {
+		err = fmt.Errorf("unable to find the repository in revision url: %s", revisionURL.String())
+		return
+	}
+	owner = parts[1]
+	repo = parts[2]
+	return
+}
+
+// parseRepository will return the owner and repository name from a repository value
+//
+// Supports: owner/repo, https://github.com/owner/repo(.git) and git@github.com:owner/repo(.git)
+func parseRepository(repository string) (owner, repo string, err error) {
+	value := strings.TrimSuffix(strings.TrimSpace(repository), ".git")
+	if strings.Contains(value, "://") {
+		var repositoryURL *url.URL
+		if repositoryURL, err = url.Parse(value); err != nil {
+			return
+		}
+		value = repositoryURL.Path
+	} else if index := strings.Index(value, ":"); index >= 0 {
+		value = value[index+1:]
+	}
+	parts := strings.Split(strings.Trim(value, "/"), "/")
+	if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+		err = fmt.Errorf("invalid repository: %s", repository)
+		return
+	}
+	owner = parts[0]
+	repo = parts[1]
+	return
+}
+
+// githubDeployment is a GitHub deployment (only the fields used)
+type githubDeployment struct {
+	Environment string          `json:"environment,omitempty"`
+	ID          int64           `json:"id,omitempty"`
+	Payload     json.RawMessage `json:"payload,omitempty"` // Object or string (depends on the creator)
+	SHA         string          `json:"sha,omitempty"`
+}
+
+// deploymentRequest is the data payload to create a GitHub deployment
+type deploymentRequest struct {
+	AutoMerge        bool                   `json:"auto_merge"`
+	Description      string                 `json:"description"`
+	Environment      string                 `json:"environment"`
+	Payload          map[string]interface{} `json:"payload"`
+	Ref              string                 `json:"ref"`
+	RequiredContexts []string               `json:"required_contexts"`
+}
+
+// deploymentStatusRequest is the data payload to create a GitHub deployment status
+type deploymentStatusRequest struct {
+	AutoInactive bool   `json:"auto_inactive"`
+	Description  string `json:"description"`
+	LogURL       string `json:"log_url"`
+	State        string `json:"state"`
+}
+
+// postCommitStatus will fire the http/post request to GitHub to update the commit status
+func postCommitStatus(ctx context.Context, owner, repo, commit string, status *payload) error {
+	return githubRequest(
+		withOwnerCredentials(ctx, owner), http.MethodPost, fmt.Sprintf("/repos/%s/%s/statuses/%s", owner, repo, commit),
+		status, nil, http.StatusCreated,
+	)
+}
+
+// getCommitStatuses will return the statuses of a commit (newest first, first page only)
+func getCommitStatuses(ctx context.Context, owner, repo, commit string) (statuses []*payload, err error) {
+	err = githubRequest(
+		withOwnerCredentials(ctx, owner), http.MethodGet, fmt.Sprintf("/repos/%s/%s/commits/%s/statuses?per_page=100", owner, repo, commit),
+		nil, &statuses, http.StatusOK,
+	)
+	return
+}
+
+// getDeployments will get the GitHub deployments for a commit and environment
+func getDeployments(ctx context.Context, owner, repo, commit, environment string) (deployments []*githubDeployment, err error) {
+	err = githubRequest(
+		withOwnerCredentials(ctx, owner), http.MethodGet, fmt.Sprintf(
+			"/repos/%s/%s/deployments?sha=%s&environment=%s",
+			owner, repo, url.QueryEscape(commit), url.QueryEscape(environment),
+		), nil, &deployments, http.StatusOK,
+	)
+	return
+}
+
+// createDeployment will create a GitHub deployment
+func createDeployment(ctx context.Context, owner, repo string, deployment *deploymentRequest) (created *githubDeployment, err error) {
+	err = githubRequest(
+		withOwnerCredentials(ctx, owner), http.MethodPost, fmt.Sprintf("/repos/%s/%s/deployments", owner, repo),
+		deployment, &created, http.StatusCreated,
+	)
+	return
+}
+
+// createDeploymentStatus will create a GitHub deployment status
+func createDeploymentStatus(ctx context.Context, owner, repo string, deploymentID int64, status *deploymentStatusRequest) error {
+	return githubRequest(
+		withOwnerCredentials(ctx, owner), http.MethodPost, fmt.Sprintf("/repos/%s/%s/deployments/%d/statuses", owner, repo, deploymentID),
+		status, nil, http.StatusCreated,
+	)
+}
+
+// githubTokenUser is the user of a token (GET /user) and the scopes of a classic token (X-OAuth-Scopes)
+type githubTokenUser struct {
+	ClassicToken bool     `json:"-"` // Fine-grained and app tokens have no scopes header
+	Login        string   `json:"login"`
+	Scopes       []string `json:"-"`
+}
+
+// setScopes will set the scopes of the token from the response headers
+func (u *githubTokenUser) setScopes(header http.Header) {
+	var values []string
+	values, u.ClassicToken = header[http.CanonicalHeaderKey("X-OAuth-Scopes")]
+	u.Scopes = nil
+	for _, value := range values {
+		for _, scope := range strings.Split(value, ",") {
+			if scope = strings.TrimSpace(scope); len(scope) > 0 {
+				u.Scopes = append(u.Scopes, scope)
+			}
+		}
+	}
+}
+
+// githubRateLimit is the rate limit of a token (GET /rate_limit, only the fields used)
+type githubRateLimit struct {
+	Resources struct {
+		Core struct {
+			Limit     int   `json:"limit"`
+			Remaining int   `json:"remaining"`
+			Reset     int64 `json:"reset"`
+		} `json:"core"`
+	} `json:"resources"`
+}
+
+// getTokenUser will return the user and scopes of the token
+func getTokenUser(ctx context.Context) (user *githubTokenUser, err error) {
+	user = &githubTokenUser{}
+	if err = githubRequest(ctx, http.MethodGet, "/user", nil, user, http.StatusOK); err != nil {
+		user = nil
+	}
+	return
+}
+
+// getRateLimit will return the rate limit of the token
+func getRateLimit(ctx context.Context) (rateLimit *githubRateLimit, err error) {
+	err = githubRequest(ctx, http.MethodGet, "/rate_limit", nil, &rateLimit, http.StatusOK)
+	return
+}
+
+// githubError is an unexpected response from the GitHub API
+type githubError struct {
+	Body       string
+	StatusCode int
+}
+
+// Error will return the error message
+func (e *githubError) Error() string {
+	return fmt.Sprintf("unexpected response from GitHub, code: %d body: %s", e.StatusCode, e.Body)
+}
+
+// githubRequest will fire a request to the GitHub API (JSON body) and decode the JSON response into result
+// A 401 refreshes the token and retries once if the provider has a new token (rotated tokens)
+func githubRequest(ctx context.Context, method, path string, body, result interface{}, expectedStatus int) (err error) {
+
+	// Get the token (cached)
+	var token string
+	if token, err = getGithubToken(ctx, false); err != nil {
+		return
+	}
+
+	// Fire the request
+	var ghErr *githubError
+	if err = sendGithubRequest(ctx, token, method, path, body, result, expectedStatus); err == nil ||
+		!errors.As(err, &ghErr) || ghErr.StatusCode != http.StatusUnauthorized {
+		return
+	}
+
+	// Refresh the token and retry once (only if the provider returned a new token)
+	var refreshed string
+	if refreshed, err = getGithubToken(ctx, true); err != nil {
+		return
+	} else if refreshed != token {
+		log.Printf("unauthorized by GitHub, retrying with a refreshed token from: %s", getCredentials(ctx).Name())
+		if err = sendGithubRequest(ctx, refreshed, method, path, body, result, expectedStatus); err == nil ||
+			!errors.As(err, &ghErr) || ghErr.StatusCode != http.StatusUnauthorized {
+			return
+		}
+	}
+
+	// Still unauthorized, reload the configuration (and token) on the next use
+	invalidateConfiguration()
+	return ghErr
+}
+
+// sendGithubRequest will fire a single request to the GitHub API with the token
+func sendGithubRequest(ctx context.Context, token, method, path string, body, result interface{},
+	expectedStatus int,
+) (err error) {
+
+	// Create the GitHub payload
+	var b bytes.Buffer
+	if body != nil {
+		if err = json.NewEncoder(&b).Encode(body); err != nil {
+			return
+		}
+	}
+
+	// Create the request
+	var req *http.Request
+	if req, err = http.NewRequestWithContext(ctx, method, getConfig().GithubAPIURL+path, &b); err != nil {
+		return
+	}
+
+	// Set the headers
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "token "+token)
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	// Dry-run: log the request instead of firing it (no response)
+	if getConfig().DryRun {
+		recordDryRunRequest(req, b.Bytes(), false)
+		return
+	}
+
+	// Fire the request
+	var response *http.Response
+	if response, err = http.DefaultClient.Do(req); err != nil {
+		return
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+
+	// Check for success
+	if response.StatusCode != expectedStatus {
+		resBody, _ := io.ReadAll(response.Body)
+		err = &githubError{Body: string(resBody), StatusCode: response.StatusCode}
+		return
+	}
+
+	// Decode the response (and the scopes of the token for the user)
+	if result != nil {
+		err = json.NewDecoder(response.Body).Decode(result)
+	}
+	if user, ok := result.(*githubTokenUser); ok && err == nil {
+		user.setScopes(response.Header)
+	}
+	return
+}
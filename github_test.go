@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// TestGetGitHubRepository will test getGitHubRepository() and isGitHubURL()
+func TestGetGitHubRepository(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		revisionURL   string
+		expectedOwner string
+		expectedRepo  string
+		expectedError bool
+		expectedURL   bool
+	}{
+		{"https://github.com/mrz1836/codepipeline-to-github/commit/25c0c3e", "mrz1836", "codepipeline-to-github", false, true},
+		{"https://us-east-1.console.aws.amazon.com/codesuite/settings/connections/redirect?connectionArn=arn&FullRepositoryId=mrz1836/infra&Commit=25c0c3e", "mrz1836", "infra", false, true},
+		{"https://github.example.com/mrz1836/codepipeline-to-github/commit/25c0c3e", "mrz1836", "codepipeline-to-github", false, false},
+		{"not a url", "", "", true, false},
+	}
+
+	for _, test := range tests {
+		revisionURL, err := url.Parse(test.revisionURL)
+		if err != nil {
+			t.Fatal("error occurred", err.Error())
+		}
+		owner, repo, err := getGitHubRepository(revisionURL)
+		if err == nil && test.expectedError {
+			t.Errorf("%s Failed: [%s] expected to throw an error, but no error", t.Name(), test.revisionURL)
+		} else if err != nil && !test.expectedError {
+			t.Errorf("%s Failed: [%s] error occurred [%s]", t.Name(), test.revisionURL, err.Error())
+		} else if owner != test.expectedOwner || repo != test.expectedRepo {
+			t.Errorf("%s Failed: [%s] expected [%s/%s] got [%s/%s]", t.Name(), test.revisionURL, test.expectedOwner, test.expectedRepo, owner, repo)
+		} else if isGitHubURL(revisionURL) != test.expectedURL {
+			t.Errorf("%s Failed: [%s] expected github url [%t]", t.Name(), test.revisionURL, test.expectedURL)
+		}
+	}
+}
+
+// TestParseRepository will test parseRepository()
+func TestParseRepository(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		repository    string
+		expectedOwner string
+		expectedRepo  string
+		expectedError bool
+	}{
+		{"mrz1836/codepipeline-to-github", "mrz1836", "codepipeline-to-github", false},
+		{"https://github.com/mrz1836/codepipeline-to-github.git", "mrz1836", "codepipeline-to-github", false},
+		{"git@github.com:mrz1836/codepipeline-to-github.git", "mrz1836", "codepipeline-to-github", false},
+		{"codepipeline-to-github", "", "", true},
+		{"", "", "", true},
+	}
+
+	for _, test := range tests {
+		owner, repo, err := parseRepository(test.repository)
+		if err == nil && test.expectedError {
+			t.Errorf("%s Failed: [%s] expected to throw an error, but no error", t.Name(), test.repository)
+		} else if err != nil && !test.expectedError {
+			t.Errorf("%s Failed: [%s] error occurred [%s]", t.Name(), test.repository, err.Error())
+		} else if owner != test.expectedOwner || repo != test.expectedRepo {
+			t.Errorf("%s Failed: [%s] expected [%s/%s] got [%s/%s]", t.Name(), test.repository, test.expectedOwner, test.expectedRepo, owner, repo)
+		}
+	}
+}
+
+// TestPostCommitStatus will test postCommitStatus()
+func TestPostCommitStatus(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/mrz1836/codepipeline-to-github/statuses/25c0c3e" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		} else if r.Header.Get("Authorization") != "token test-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var p payload
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil || p.State != "success" {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	setConfig(t, func(c *loadedConfiguration) {
+		c.GithubAPIURL = server.URL
+		c.GithubAccessToken = "test-token"
+	})
+
+	// Valid status
+	if err := postCommitStatus(context.Background(), "mrz1836", "codepipeline-to-github", "25c0c3e", &payload{
+		Context: githubContext,
+		State:   "success",
+	}); err != nil {
+		t.Fatal("error occurred", err.Error())
+	}
+
+	// Unknown commit
+	if err := postCommitStatus(context.Background(), "mrz1836", "codepipeline-to-github", "unknown", &payload{
+		Context: githubContext,
+		State:   "success",
+	}); err == nil {
+		t.Fatal("error should have occurred")
+	}
+}
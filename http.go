@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// HTTP defaults
+const (
+	defaultHTTPAddress = ":8080"
+	httpAuthIAM        = "iam"
+	httpAuthSecret     = "secret"
+	maxHTTPBodySize    = 256 * 1024
+)
+
+// iamCallerKey is the context key of the IAM caller (Function URL or API Gateway with IAM auth)
+type iamCallerKey struct{}
+
+// refreshRequest is the body of a manual status refresh (or a raw event with a detail)
+type refreshRequest struct {
+	ExecutionID string `json:"execution_id"`
+	Pipeline    string `json:"pipeline"`
+}
+
+// httpResponse is the JSON response of the http handler
+type httpResponse struct {
+	Error    string           `json:"error,omitempty"`
+	Requests []*dryRunRequest `json:"requests,omitempty"` // Dry-run
+	Status   string           `json:"status,omitempty"`
+}
+
+// statusHandler will run the status processing for a manual refresh (POST pipeline + execution id or a raw event)
+func statusHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, &httpResponse{Error: "method not allowed"})
+		return
+	}
+
+	// Load the configuration (shared secret or IAM)
+	if err := getConfiguration(); err != nil {
+		log.Printf("unable to load the configuration: %s", err.Error())
+		writeJSON(w, http.StatusInternalServerError, &httpResponse{Error: "invalid configuration"})
+		return
+	}
+	if !isAuthorized(r) {
+		writeJSON(w, http.StatusUnauthorized, &httpResponse{Error: "unauthorized"})
+		return
+	}
+
+	// Read the event from the body
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxHTTPBodySize))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, &httpResponse{Error: err.Error()})
+		return
+	}
+	var ev event
+	if ev, err = getHTTPEvent(body); err != nil {
+		writeJSON(w, http.StatusBadRequest, &httpResponse{Error: err.Error()})
+		return
+	}
+
+	// Process the event (same as the Lambda events), a dry-run returns the requests that would have been sent
+	response := &httpResponse{Status: "ok"}
+	if getConfig().DryRun {
+		response.Status = statusDryRun
+		response.Requests, err = runDryRun(func() error {
+			return ProcessEvent(ev)
+		})
+	} else {
+		err = ProcessEvent(ev)
+	}
+	if err != nil {
+		status := http.StatusBadGateway
+		if isPermanentError(err) {
+			status = http.StatusUnprocessableEntity
+		}
+		writeJSON(w, status, &httpResponse{Error: err.Error(), Requests: response.Requests})
+		return
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// isAuthorized will check the request for the shared secret (Authorization: Bearer) or the IAM caller
+func isAuthorized(r *http.Request) bool {
+	cfg := getConfig()
+	if cfg.HTTPAuth == httpAuthIAM {
+		caller, _ := r.Context().Value(iamCallerKey{}).(string)
+		return len(caller) > 0
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return len(cfg.HTTPSharedSecret) > 0 &&
+		subtle.ConstantTimeCompare([]byte(token), []byte(cfg.HTTPSharedSecret)) == 1
+}
+
+// getHTTPEvent will return the event from a refresh request (pipeline + execution id) or a raw event
+func getHTTPEvent(body []byte) (ev event, err error) {
+	var raw struct {
+		Detail json.RawMessage `json:"detail"`
+		refreshRequest
+	}
+	if err = json.Unmarshal(body, &raw); err != nil {
+		return
+	}
+
+	// Raw event (EventBridge)
+	if len(raw.Detail) > 0 {
+		err = json.Unmarshal(body, &ev)
+		return
+	}
+
+	// Refresh the status of a pipeline execution
+	if len(raw.Pipeline) == 0 || len(raw.ExecutionID) == 0 {
+		err = errors.New("missing pipeline and execution_id (or a raw event)")
+		return
+	}
+	ev = event{
+		Detail: &detail{
+			ExecutionID: raw.ExecutionID,
+			Pipeline:    raw.Pipeline,
+		},
+		DetailType: detailTypePipelineStateChange,
+	}
+	return
+}
+
+// writeJSON will write the JSON response
+func writeJSON(w http.ResponseWriter, status int, response *httpResponse) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// getFunctionURLRequest will return the request if the payload is a Function URL or API Gateway (v2) request
+func getFunctionURLRequest(raw json.RawMessage) *events.LambdaFunctionURLRequest {
+	var request events.LambdaFunctionURLRequest
+	if err := json.Unmarshal(raw, &request); err != nil || len(request.RequestContext.HTTP.Method) == 0 {
+		return nil
+	}
+	return &request
+}
+
+// handleFunctionURLRequest will run the http handler (by path) for a Function URL or API Gateway (v2 payload) request
+func handleFunctionURLRequest(ctx context.Context,
+	request *events.LambdaFunctionURLRequest,
+) (*events.LambdaFunctionURLResponse, error) {
+
+	// Decode the body
+	body := []byte(request.Body)
+	if request.IsBase64Encoded {
+		var err error
+		if body, err = base64.StdEncoding.DecodeString(request.Body); err != nil {
+			return nil, err
+		}
+	}
+
+	// IAM callers are authenticated by the Function URL or API Gateway (AWS_IAM)
+	if authorizer := request.RequestContext.Authorizer; authorizer != nil && authorizer.IAM != nil {
+		ctx = context.WithValue(ctx, iamCallerKey{}, authorizer.IAM.UserARN)
+	}
+
+	// Create the http request
+	r, err := http.NewRequestWithContext(
+		ctx, request.RequestContext.HTTP.Method, "https://"+request.RequestContext.DomainName+request.RawPath,
+		bytes.NewReader(body),
+	)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range request.Headers {
+		r.Header.Set(name, value)
+	}
+
+	// Run the handler
+	w := &bufferedResponse{header: http.Header{}, status: http.StatusOK}
+	getHTTPHandler(request.RawPath)(w, r)
+	response := &events.LambdaFunctionURLResponse{
+		Body:       w.body.String(),
+		Headers:    map[string]string{},
+		StatusCode: w.status,
+	}
+	for name := range w.header {
+		response.Headers[name] = w.header.Get(name)
+	}
+	return response, nil
+}
+
+// bufferedResponse is a http.ResponseWriter that keeps the response in memory (Lambda responses)
+type bufferedResponse struct {
+	body   bytes.Buffer
+	header http.Header
+	status int
+}
+
+// Header will return the response headers
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+// Write will write to the response body
+func (b *bufferedResponse) Write(data []byte) (int, error) {
+	return b.body.Write(data)
+}
+
+// WriteHeader will set the response status code
+func (b *bufferedResponse) WriteHeader(status int) {
+	b.status = status
+}
+
+// runServer will run the standalone http server (serve -addr :8080)
+func runServer(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	address := flags.String("addr", defaultHTTPAddress, "address to listen on")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	// Check the configuration before listening (IAM auth is only available behind a Function URL or API Gateway)
+	if err := getConfiguration(); err != nil {
+		return err
+	} else if cfg := getConfig(); cfg.HTTPAuth != httpAuthSecret || len(cfg.HTTPSharedSecret) == 0 {
+		return errors.New("the standalone server requires HTTP_AUTH=secret and HTTP_SHARED_SECRET")
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, &httpResponse{Status: "ok"})
+	})
+	mux.HandleFunc(githubWebhookPath, githubWebhookHandler)
+	mux.HandleFunc("/", statusHandler)
+
+	server := &http.Server{
+		Addr:              *address,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		WriteTimeout:      time.Minute,
+	}
+	log.Printf("listening on: %s", *address)
+	return server.ListenAndServe()
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// setHTTPEnvironment will set the environment for the http handler (testing stage, no KMS, no cached configuration)
+func setHTTPEnvironment(t *testing.T, auth string) {
+	resetConfiguration()
+	if awsSession == nil {
+		awsSession = session.Must(session.NewSession(&aws.Config{
+			Region: aws.String("us-east-1"),
+		}))
+	}
+	for name, value := range map[string]string{
+		"APPLICATION_STAGE_NAME": "testing",
+		"AWS_REGION":             "us-east-1",
+		"GITHUB_ACCESS_TOKEN":    "1234567",
+		"HTTP_AUTH":              auth,
+		"HTTP_SHARED_SECRET":     "test-secret",
+	} {
+		_ = os.Setenv(name, value)
+	}
+	t.Cleanup(func() {
+		_ = os.Unsetenv("HTTP_AUTH")
+		_ = os.Unsetenv("HTTP_SHARED_SECRET")
+		resetConfiguration()
+	})
+}
+
+// TestGetHTTPEvent will test getHTTPEvent()
+func TestGetHTTPEvent(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		body               string
+		expectedDetailType string
+		expectedPipeline   string
+		expectedError      bool
+	}{
+		{`{"pipeline":"my-pipeline","execution_id":"12345"}`, detailTypePipelineStateChange, "my-pipeline", false},
+		{`{"detail-type":"CodePipeline Stage Execution State Change","detail":{"pipeline":"my-pipeline"}}`, detailTypeStageStateChange, "my-pipeline", false},
+		{`{"pipeline":"my-pipeline"}`, "", "", true},
+		{`not json`, "", "", true},
+	}
+
+	for _, test := range tests {
+		ev, err := getHTTPEvent([]byte(test.body))
+		if err == nil && test.expectedError {
+			t.Errorf("%s Failed: [%s] expected to throw an error, but no error", t.Name(), test.body)
+		} else if err != nil && !test.expectedError {
+			t.Errorf("%s Failed: [%s] error occurred [%s]", t.Name(), test.body, err.Error())
+		} else if err == nil && (ev.DetailType != test.expectedDetailType || ev.Detail.Pipeline != test.expectedPipeline) {
+			t.Errorf("%s Failed: [%s] expected [%s/%s] got [%s/%s]", t.Name(), test.body,
+				test.expectedDetailType, test.expectedPipeline, ev.DetailType, ev.Detail.Pipeline)
+		}
+	}
+}
+
+// TestStatusHandler will test statusHandler() (shared secret)
+func TestStatusHandler(t *testing.T) {
+	setHTTPEnvironment(t, httpAuthSecret)
+
+	var tests = []struct {
+		name           string
+		method         string
+		authorization  string
+		body           string
+		expectedStatus int
+	}{
+		{"wrong method", http.MethodGet, "Bearer test-secret", "", http.StatusMethodNotAllowed},
+		{"missing secret", http.MethodPost, "", `{"pipeline":"my-pipeline","execution_id":"12345"}`, http.StatusUnauthorized},
+		{"wrong secret", http.MethodPost, "Bearer wrong", `{"pipeline":"my-pipeline","execution_id":"12345"}`, http.StatusUnauthorized},
+		{"invalid body", http.MethodPost, "Bearer test-secret", `{"pipeline":"my-pipeline"}`, http.StatusBadRequest},
+		{"invalid event", http.MethodPost, "Bearer test-secret", `{"detail-type":"CodePipeline Stage Execution State Change","detail":{"pipeline":"my-pipeline"}}`, http.StatusUnprocessableEntity},
+		{"ignored event", http.MethodPost, "Bearer test-secret", `{"detail-type":"Something Else","detail":{}}`, http.StatusOK},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(test.method, "/", strings.NewReader(test.body))
+		if len(test.authorization) > 0 {
+			r.Header.Set("Authorization", test.authorization)
+		}
+		w := httptest.NewRecorder()
+		statusHandler(w, r)
+		if w.Code != test.expectedStatus {
+			t.Errorf("%s Failed: [%s] expected status [%d] got [%d] body [%s]", t.Name(), test.name, test.expectedStatus, w.Code, w.Body.String())
+		}
+	}
+}
+
+// TestHandleFunctionURLRequest will test getFunctionURLRequest() and handleFunctionURLRequest() (IAM)
+func TestHandleFunctionURLRequest(t *testing.T) {
+	setHTTPEnvironment(t, httpAuthIAM)
+
+	newRequest := func(iam bool) json.RawMessage {
+		request := events.LambdaFunctionURLRequest{
+			Body:            base64.StdEncoding.EncodeToString([]byte(`{"detail-type":"Something Else","detail":{}}`)),
+			IsBase64Encoded: true,
+			RawPath:         "/",
+			RequestContext: events.LambdaFunctionURLRequestContext{
+				DomainName: "abc.lambda-url.us-east-1.on.aws",
+				HTTP:       events.LambdaFunctionURLRequestContextHTTPDescription{Method: http.MethodPost},
+			},
+		}
+		if iam {
+			request.RequestContext.Authorizer = &events.LambdaFunctionURLRequestContextAuthorizerDescription{
+				IAM: &events.LambdaFunctionURLRequestContextAuthorizerIAMDescription{
+					UserARN: "arn:aws:iam::123456789012:user/jane",
+				},
+			}
+		}
+		raw, err := json.Marshal(request)
+		if err != nil {
+			t.Fatal("error occurred", err.Error())
+		}
+		return raw
+	}
+
+	if request := getFunctionURLRequest(json.RawMessage(`{"detail-type":"Something Else","detail":{}}`)); request != nil {
+		t.Fatal("expected no request for an EventBridge event")
+	}
+
+	for iam, expectedStatus := range map[bool]int{true: http.StatusOK, false: http.StatusUnauthorized} {
+		response, err := handleRequest(context.Background(), newRequest(iam))
+		if err != nil {
+			t.Fatal("error occurred", err.Error())
+		}
+		urlResponse, ok := response.(*events.LambdaFunctionURLResponse)
+		if !ok {
+			t.Fatal("expected a function url response", response)
+		} else if urlResponse.StatusCode != expectedStatus {
+			t.Errorf("%s Failed: [iam: %t] expected status [%d] got [%d]", t.Name(), iam, expectedStatus, urlResponse.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/codecommit/codecommitiface"
+)
+
+// Publisher defaults
+const (
+	defaultPublisherTimeout = 4 * time.Second
+	providerCodeCommit      = "codecommit"
+	providerGitHub          = "github"
+	publisherCodeCommit     = "codecommit"
+	publisherGitHub         = "github"
+	publisherSlack          = "slack"
+	publisherWebhook        = "webhook"
+)
+
+// statusUpdate is the normalized pipeline status update (delivered to every publisher)
+type statusUpdate struct {
+	ArtifactName string `json:"artifact_name,omitempty"`
+	Commit       string `json:"commit"`
+	Context      string `json:"context"`
+	Description  string `json:"description"`
+	ExecutionID  string `json:"execution_id,omitempty"`
+	Owner        string `json:"owner,omitempty"`
+	Pipeline     string `json:"pipeline,omitempty"`
+	Provider     string `json:"provider"`
+	PullRequest  int    `json:"pull_request,omitempty"`
+	Repository   string `json:"repository"`
+	State        string `json:"state"`
+	TargetURL    string `json:"target_url"`
+}
+
+// statusPublisher delivers a status update to a destination (GitHub, CodeCommit, chat, webhooks, etc.)
+type statusPublisher interface {
+	Name() string
+	Publish(ctx context.Context, update *statusUpdate) error
+}
+
+// getPublishers will return the configured publishers (PUBLISHERS or the publishers of the pipeline route)
+func getPublishers(codeCommitSvc codecommitiface.CodeCommitAPI, pipelineName string) ([]statusPublisher, error) {
+	if route := getPipelineRoute(pipelineName); route != nil && len(route.Publishers) > 0 {
+		return newPublishers(&getConfig().configuration, codeCommitSvc, route.Publishers)
+	}
+	cfg := getConfig()
+	return newPublishers(&cfg.configuration, codeCommitSvc, cfg.Publishers)
+}
+
+// newPublishers will return the publishers by name (the webhooks are from the configuration)
+func newPublishers(cfg *configuration, codeCommitSvc codecommitiface.CodeCommitAPI,
+	names []string,
+) (publishers []statusPublisher, err error) {
+	for _, name := range names {
+		switch strings.TrimSpace(name) {
+		case publisherGitHub:
+			publishers = append(publishers, &githubPublisher{})
+		case publisherCodeCommit:
+			publishers = append(publishers, &codeCommitPublisher{codeCommitSvc: codeCommitSvc})
+		case publisherSlack:
+			if len(cfg.SlackWebhookURL) == 0 {
+				return nil, errors.New("missing SLACK_WEBHOOK_URL for the slack publisher")
+			}
+			publishers = append(publishers, &slackPublisher{webhookURL: cfg.SlackWebhookURL})
+		case publisherWebhook:
+			if len(cfg.WebhookURLs) == 0 {
+				return nil, errors.New("missing WEBHOOK_URLS for the webhook publisher")
+			}
+			for _, webhookURL := range cfg.WebhookURLs {
+				publishers = append(publishers, &webhookPublisher{webhookURL: webhookURL})
+			}
+		default:
+			return nil, fmt.Errorf("unknown publisher: %s", name)
+		}
+	}
+	return
+}
+
+// publishStatus will deliver the update to every publisher concurrently (each with a timeout)
+// A failing publisher never prevents the others from publishing, all errors are returned together
+func publishStatus(ctx context.Context, publishers []statusPublisher, update *statusUpdate) error {
+	errs := make([]error, len(publishers))
+	var wg sync.WaitGroup
+	for i, publisher := range publishers {
+		wg.Add(1)
+		go func(i int, publisher statusPublisher) {
+			defer wg.Done()
+			timeout := getConfig().PublisherTimeout
+			if timeout <= 0 {
+				timeout = defaultPublisherTimeout
+			}
+			publishCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			if err := publisher.Publish(publishCtx, update); err != nil {
+				log.Printf("publisher: %s failed for commit: %s error: %s", publisher.Name(), update.Commit, err.Error())
+				errs[i] = fmt.Errorf("publisher %s: %w", publisher.Name(), err)
+			}
+		}(i, publisher)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// githubPublisher will post a commit status to GitHub (GitHub sources only)
+type githubPublisher struct{}
+
+// Name will return the publisher name
+func (p *githubPublisher) Name() string {
+	return publisherGitHub
+}
+
+// Publish will post the commit status
+func (p *githubPublisher) Publish(ctx context.Context, update *statusUpdate) error {
+	if update.Provider != providerGitHub {
+		return nil
+	}
+	return postCommitStatus(ctx, update.Owner, update.Repository, update.Commit, &payload{
+		Context:     update.Context,
+		Description: update.Description,
+		State:       update.State,
+		TargetURL:   update.TargetURL,
+	})
+}
+
+// codeCommitPublisher will comment on the matching CodeCommit pull requests (CodeCommit sources only)
+type codeCommitPublisher struct {
+	codeCommitSvc codecommitiface.CodeCommitAPI
+}
+
+// Name will return the publisher name
+func (p *codeCommitPublisher) Name() string {
+	return publisherCodeCommit
+}
+
+// Publish will comment on the pull requests
+func (p *codeCommitPublisher) Publish(ctx context.Context, update *statusUpdate) error {
+	if update.Provider != providerCodeCommit {
+		return nil
+	} else if getConfig().DryRun {
+		log.Printf("dry-run: skipping CodeCommit feedback for repository: %s commit: %s", update.Repository, update.Commit)
+		return nil
+	}
+	return postCodeCommitFeedback(ctx, p.codeCommitSvc, update)
+}
+
+// slackPublisher will post a message to a Slack (compatible) incoming webhook
+type slackPublisher struct {
+	webhookURL string
+}
+
+// Name will return the publisher name
+func (p *slackPublisher) Name() string {
+	return publisherSlack
+}
+
+// Publish will post the chat message
+func (p *slackPublisher) Publish(ctx context.Context, update *statusUpdate) error {
+	repository := update.Repository
+	if len(update.Owner) > 0 {
+		repository = update.Owner + "/" + repository
+	}
+	commit := update.Commit
+	if len(commit) > 7 {
+		commit = commit[:7]
+	}
+	return postJSON(ctx, p.webhookURL, map[string]string{
+		"text": fmt.Sprintf("*%s* %s `%s` (%s): %s <%s|View>",
+			update.State, repository, commit, update.Pipeline, update.Description, update.TargetURL),
+	})
+}
+
+// webhookPublisher will post the status update (JSON) to a webhook url
+type webhookPublisher struct {
+	webhookURL string
+}
+
+// Name will return the publisher name (includes the host)
+func (p *webhookPublisher) Name() string {
+	if webhookURL, err := url.Parse(p.webhookURL); err == nil {
+		return publisherWebhook + ":" + webhookURL.Host
+	}
+	return publisherWebhook
+}
+
+// Publish will post the status update
+func (p *webhookPublisher) Publish(ctx context.Context, update *statusUpdate) error {
+	return postJSON(ctx, p.webhookURL, update)
+}
+
+// postJSON will fire a http/post request with a JSON body (expects a 2xx response)
+func postJSON(ctx context.Context, postURL string, data interface{}) (err error) {
+
+	// Create the payload
+	var b bytes.Buffer
+	if err = json.NewEncoder(&b).Encode(data); err != nil {
+		return
+	}
+
+	// Create the request
+	var req *http.Request
+	if req, err = http.NewRequestWithContext(ctx, http.MethodPost, postURL, &b); err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	// Dry-run: log the request instead of firing it
+	if getConfig().DryRun {
+		recordDryRunRequest(req, b.Bytes(), true)
+		return
+	}
+
+	// Fire the request
+	var response *http.Response
+	if response, err = http.DefaultClient.Do(req); err != nil {
+		return
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+
+	// Check for success
+	if response.StatusCode < http.StatusOK || response.StatusCode >= http.StatusMultipleChoices {
+		resBody, _ := io.ReadAll(response.Body)
+		err = fmt.Errorf("unexpected response, code: %d body: %s", response.StatusCode, string(resBody))
+	}
+	return
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// mockPublisher is a publisher that records the updates (or fails)
+type mockPublisher struct {
+	delay     time.Duration
+	err       error
+	lock      sync.Mutex
+	name      string
+	published int32
+	states    []string // States of the published updates (in order)
+}
+
+// Name will return the publisher name
+func (p *mockPublisher) Name() string {
+	return p.name
+}
+
+// Publish will record the update (or fail)
+func (p *mockPublisher) Publish(ctx context.Context, update *statusUpdate) error {
+	select {
+	case <-time.After(p.delay):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if p.err != nil {
+		return p.err
+	}
+	atomic.AddInt32(&p.published, 1)
+	p.lock.Lock()
+	p.states = append(p.states, update.State)
+	p.lock.Unlock()
+	return nil
+}
+
+// TestPublishStatus will test publishStatus()
+func TestPublishStatus(t *testing.T) {
+
+	setConfig(t, func(c *loadedConfiguration) {
+		c.PublisherTimeout = 50 * time.Millisecond
+	})
+
+	github := &mockPublisher{name: "github"}
+	slack := &mockPublisher{name: "slack", err: errors.New("slack is down")}
+	slow := &mockPublisher{name: "slow", delay: time.Second}
+
+	err := publishStatus(context.Background(), []statusPublisher{github, slack, slow}, &statusUpdate{Commit: "25c0c3e"})
+	if err == nil {
+		t.Fatal("error should have occurred")
+	} else if atomic.LoadInt32(&github.published) != 1 {
+		t.Fatal("github status should have been published")
+	} else if !strings.Contains(err.Error(), "publisher slack: slack is down") {
+		t.Fatal("expected the slack error", err.Error())
+	} else if !strings.Contains(err.Error(), "publisher slow: context deadline exceeded") {
+		t.Fatal("expected the slow publisher to time out", err.Error())
+	}
+
+	// No errors
+	if err = publishStatus(context.Background(), []statusPublisher{github}, &statusUpdate{}); err != nil {
+		t.Fatal("error occurred", err.Error())
+	}
+}
+
+// TestGetPublishers will test getPublishers()
+func TestGetPublishers(t *testing.T) {
+
+	// Default publishers
+	setConfig(t, func(c *loadedConfiguration) {
+		c.Publishers = []string{"github", "codecommit"}
+	})
+	publishers, err := getPublishers(&mockCodeCommitClient{}, "")
+	if err != nil {
+		t.Fatal("error occurred", err.Error())
+	} else if len(publishers) != 2 {
+		t.Fatal("expected 2 publishers", len(publishers))
+	}
+
+	// Missing slack url
+	setConfig(t, func(c *loadedConfiguration) {
+		c.Publishers = []string{"github", "slack"}
+	})
+	if _, err = getPublishers(nil, ""); err == nil {
+		t.Fatal("error should have occurred")
+	}
+
+	// Multiple webhooks
+	setConfig(t, func(c *loadedConfiguration) {
+		c.Publishers = []string{"github", "slack", "webhook"}
+		c.SlackWebhookURL = "https://hooks.slack.com/services/T000/B000/XXXX"
+		c.WebhookURLs = []string{"https://example.com/one", "https://example.org/two"}
+	})
+	if publishers, err = getPublishers(nil, ""); err != nil {
+		t.Fatal("error occurred", err.Error())
+	} else if len(publishers) != 4 || publishers[3].Name() != "webhook:example.org" {
+		t.Fatal("publishers were not as expected", len(publishers))
+	}
+
+	// Unknown publisher
+	setConfig(t, func(c *loadedConfiguration) {
+		c.Publishers = []string{"pager"}
+	})
+	if _, err = getPublishers(nil, ""); err == nil {
+		t.Fatal("error should have occurred")
+	}
+}
+
+// TestWebhookPublishers will test the slack and webhook publishers
+func TestWebhookPublishers(t *testing.T) {
+	t.Parallel()
+
+	var received []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		received = append(received, body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	update := &statusUpdate{
+		Commit:     "25c0c3e61c4db2c2cde8b163b3ad096875c1ce08",
+		Owner:      "mrz1836",
+		Pipeline:   "my-pipeline",
+		Provider:   providerGitHub,
+		Repository: "codepipeline-to-github",
+		State:      "success",
+	}
+
+	if err := (&slackPublisher{webhookURL: server.URL + "/slack"}).Publish(context.Background(), update); err != nil {
+		t.Fatal("error occurred", err.Error())
+	} else if err = (&webhookPublisher{webhookURL: server.URL + "/webhook"}).Publish(context.Background(), update); err != nil {
+		t.Fatal("error occurred", err.Error())
+	} else if err = (&webhookPublisher{webhookURL: server.URL + "/fail"}).Publish(context.Background(), update); err == nil {
+		t.Fatal("error should have occurred")
+	}
+
+	if len(received) != 2 {
+		t.Fatal("expected 2 requests", len(received))
+	} else if !strings.Contains(received[0]["text"].(string), "mrz1836/codepipeline-to-github `25c0c3e`") {
+		t.Fatal("slack message was not as expected", received[0]["text"])
+	} else if received[1]["commit"] != update.Commit || received[1]["state"] != "success" {
+		t.Fatal("webhook payload was not as expected", received[1])
+	}
+}
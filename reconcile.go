@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/codepipeline"
+	"github.com/aws/aws-sdk-go/service/codepipeline/codepipelineiface"
+)
+
+// Reconciler defaults
+const (
+	detailTypeScheduledEvent = "Scheduled Event"
+	driftMissing             = "missing"
+	driftPending             = "pending"
+	driftStale               = "stale"
+	eventKindSchedule        = "schedule"
+	metricsNamespace         = "CodePipelineToGitHub"
+)
+
+// reconcileResult is the outcome of a reconciliation (emitted as metrics)
+type reconcileResult struct {
+	Checked    int `json:"StatusesChecked"`
+	Failed     int `json:"StatusesFailed"`
+	Missing    int `json:"StatusesMissing"`
+	Pending    int `json:"StatusesPending"`
+	Repaired   int `json:"StatusesRepaired"`
+	Stale      int `json:"StatusesStale"`
+	Unfinished int `json:"PipelinesUnfinished"` // Not checked within the time budget
+}
+
+// validateScheduledEvent will check a scheduled event (no required parameters)
+func validateScheduledEvent(_ event) error {
+	return nil
+}
+
+// processScheduledEvent will reconcile the statuses of the recent executions (safety net for dropped events)
+func processScheduledEvent(ev event) error {
+
+	// Stop before the function times out (RECONCILE_TIMEOUT)
+	ctx := context.Background()
+	if timeout := getConfig().ReconcileTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	result, err := reconcile(ctx, codepipeline.New(getEventSession(ev)), &githubPublisher{})
+	logMetrics(result)
+	return err
+}
+
+// reconcile will compare the recent (finished) executions with the commit statuses on GitHub for our context
+// and repair the statuses that are missing, stale or still pending
+//
+// Only the newest execution of a commit is checked (retries of the same commit),
+// a commit with an in progress execution is skipped (its pending status is correct)
+//
+// The reconciliation stops when the context is done (time budget), the pipelines are checked in a random order
+// so the pipelines not reached are checked by the next runs
+func reconcile(ctx context.Context, pipeline codepipelineiface.CodePipelineAPI,
+	publisher statusPublisher,
+) (result *reconcileResult, err error) {
+	result = &reconcileResult{}
+	cfg := getConfig()
+
+	// Get the pipeline(s)
+	var pipelineNames []string
+	for _, name := range cfg.ReconcilePipelines {
+		var names []string
+		if names, err = getPipelineNames(name, pipeline); err != nil {
+			return
+		}
+		pipelineNames = append(pipelineNames, names...)
+	}
+	rand.Shuffle(len(pipelineNames), func(i, j int) {
+		pipelineNames[i], pipelineNames[j] = pipelineNames[j], pipelineNames[i]
+	})
+
+	// Check the newest execution of each commit (collect the errors per execution)
+	var errs []error
+	checked := make(map[string]bool)
+	for i, pipelineName := range pipelineNames {
+		if reconcileStopped(ctx, result, len(pipelineNames)-i) {
+			break
+		}
+		var executions []*codepipeline.PipelineExecutionSummary
+		if executions, err = getRecentExecutions(
+			pipelineName, cfg.ReconcileMaxExecutions, cfg.ReconcileWindow, pipeline,
+		); err != nil {
+			errs = append(errs, fmt.Errorf("pipeline %s: %w", pipelineName, err))
+			continue
+		}
+		for _, execution := range executions {
+			if reconcileStopped(ctx, result, len(pipelineNames)-i) {
+				break
+			}
+			executionID := aws.StringValue(execution.PipelineExecutionId)
+			if err = reconcileExecution(ctx, pipelineName, execution, pipeline, publisher, checked, result); err != nil {
+				result.Failed++
+				errs = append(errs, fmt.Errorf("pipeline %s execution %s: %w", pipelineName, executionID, err))
+			}
+		}
+		if result.Unfinished > 0 {
+			break
+		}
+	}
+	err = errors.Join(errs...)
+	return
+}
+
+// reconcileStopped will return true (and count the unfinished pipelines) if the time budget is spent
+func reconcileStopped(ctx context.Context, result *reconcileResult, unfinished int) bool {
+	if ctx.Err() == nil {
+		return false
+	}
+	if result.Unfinished == 0 {
+		log.Printf("reconcile stopped (time budget), pipelines not finished: %d", unfinished)
+	}
+	result.Unfinished = unfinished
+	return true
+}
+
+// reconcileExecution will repair the commit status of the execution if it drifted
+func reconcileExecution(ctx context.Context, pipelineName string,
+	execution *codepipeline.PipelineExecutionSummary, pipeline codepipelineiface.CodePipelineAPI,
+	publisher statusPublisher, checked map[string]bool, result *reconcileResult,
+) error {
+	executionID := aws.StringValue(execution.PipelineExecutionId)
+
+	// Create the expected status update
+	update, err := getExecutionUpdate(pipelineName, executionID, pipeline)
+	if err != nil || update == nil {
+		return err
+	}
+	key := getStatusKey(update)
+	if checked[key] {
+		return nil
+	}
+	checked[key] = true
+	if aws.StringValue(execution.Status) == codepipeline.PipelineExecutionStatusInProgress {
+		return nil
+	}
+	result.Checked++
+	ctx = withCredentials(ctx, getRouteCredentials(pipelineName))
+
+	// Compare with the current status on GitHub
+	var statuses []*payload
+	if statuses, err = getCommitStatuses(ctx, update.Owner, update.Repository, update.Commit); err != nil {
+		return err
+	}
+	drift := getDrift(statuses, update)
+	switch drift {
+	case "":
+		return nil
+	case driftMissing:
+		result.Missing++
+	case driftPending:
+		result.Pending++
+	case driftStale:
+		result.Stale++
+	}
+
+	// Repair the status
+	log.Printf("repairing %s status: %s for %s (pipeline: %s execution: %s)", drift, update.State, key, pipelineName, executionID)
+	if err = publisher.Publish(ctx, update); err != nil {
+		return err
+	}
+	result.Repaired++
+	return nil
+}
+
+// getDrift will compare the latest status for our context with the expected update
+// Returns the kind of drift (missing, pending or stale) or empty if the status is correct
+func getDrift(statuses []*payload, update *statusUpdate) string {
+	for _, status := range statuses { // Newest first
+		if status.Context != update.Context {
+			continue
+		}
+		if status.State == update.State {
+			return ""
+		} else if status.State == "pending" {
+			return driftPending
+		}
+		return driftStale
+	}
+	return driftMissing
+}
+
+// logMetrics will log the result in the CloudWatch embedded metric format (EMF)
+func logMetrics(result *reconcileResult) {
+	if result == nil {
+		return
+	}
+	metrics := []map[string]string{
+		{"Name": "StatusesChecked", "Unit": "Count"},
+		{"Name": "StatusesFailed", "Unit": "Count"},
+		{"Name": "StatusesMissing", "Unit": "Count"},
+		{"Name": "StatusesPending", "Unit": "Count"},
+		{"Name": "StatusesRepaired", "Unit": "Count"},
+		{"Name": "StatusesStale", "Unit": "Count"},
+		{"Name": "PipelinesUnfinished", "Unit": "Count"},
+	}
+	data, err := json.Marshal(struct {
+		AWS map[string]interface{} `json:"_aws"`
+		*reconcileResult
+	}{
+		AWS: map[string]interface{}{
+			"CloudWatchMetrics": []map[string]interface{}{{
+				"Dimensions": [][]string{{}},
+				"Metrics":    metrics,
+				"Namespace":  metricsNamespace,
+			}},
+			"Timestamp": time.Now().UnixMilli(),
+		},
+		reconcileResult: result,
+	})
+	if err != nil {
+		log.Printf("unable to create the metrics: %s", err.Error())
+		return
+	}
+	fmt.Println(string(data)) // EMF requires the JSON on its own line (no log prefix)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestGetDrift will test getDrift()
+func TestGetDrift(t *testing.T) {
+	t.Parallel()
+
+	update := &statusUpdate{Context: githubContext, State: "success"}
+
+	var tests = []struct {
+		name          string
+		statuses      []*payload
+		expectedDrift string
+	}{
+		{"no statuses", nil, driftMissing},
+		{"other context", []*payload{{Context: "ci/other", State: "success"}}, driftMissing},
+		{"correct", []*payload{{Context: githubContext, State: "success"}}, ""},
+		{"pending", []*payload{{Context: githubContext, State: "pending"}}, driftPending},
+		{"stale", []*payload{{Context: githubContext, State: "failure"}}, driftStale},
+		{"newest wins", []*payload{{Context: githubContext, State: "success"}, {Context: githubContext, State: "pending"}}, ""},
+	}
+
+	for _, test := range tests {
+		if drift := getDrift(test.statuses, update); drift != test.expectedDrift {
+			t.Errorf("%s Failed: [%s] expected drift [%s] got [%s]", t.Name(), test.name, test.expectedDrift, drift)
+		}
+	}
+}
+
+// TestReconcile will test reconcile() against a GitHub stand-in
+func TestReconcile(t *testing.T) {
+
+	var statuses []*payload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/mrz1836/codepipeline-to-github/commits/25c0c3e61c4db2c2cde8b163b3ad096875c1ce08/statuses" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(statuses)
+	}))
+	defer server.Close()
+
+	setConfig(t, func(c *loadedConfiguration) {
+		c.GithubAPIURL = server.URL
+		c.GithubAccessToken = "test-token"
+	})
+
+	var tests = []struct {
+		name             string
+		pipelineName     string
+		statuses         []*payload
+		expectedChecked  int
+		expectedRepaired int
+	}{
+		{"correct", "backfill-retry", []*payload{{Context: githubContext, State: "success"}}, 1, 0},
+		{"missing", "backfill-retry", nil, 1, 1},
+		{"stale", "backfill-retry", []*payload{{Context: githubContext, State: "failure"}}, 1, 1},
+		{"pending", "backfill-retry", []*payload{{Context: githubContext, State: "pending"}}, 1, 1},
+		{"in progress", "backfill", []*payload{{Context: githubContext, State: "pending"}}, 0, 0},
+	}
+
+	for _, test := range tests {
+		statuses = test.statuses
+		setConfig(t, func(c *loadedConfiguration) {
+			c.ReconcilePipelines = []string{test.pipelineName}
+		})
+		publisher := &mockPublisher{name: publisherGitHub}
+		result, err := reconcile(context.Background(), &mockCodePipelineClient{}, publisher)
+		if err != nil {
+			t.Errorf("%s Failed: [%s] error occurred [%s]", t.Name(), test.name, err.Error())
+		} else if result.Checked != test.expectedChecked {
+			t.Errorf("%s Failed: [%s] expected only the newest execution of the commit to be checked [%d]", t.Name(), test.name, result.Checked)
+		} else if result.Repaired != test.expectedRepaired || int(publisher.published) != test.expectedRepaired {
+			t.Errorf("%s Failed: [%s] expected [%d] repaired got [%d]", t.Name(), test.name, test.expectedRepaired, result.Repaired)
+		} else if test.expectedRepaired > 0 && publisher.states[0] != "success" {
+			t.Errorf("%s Failed: [%s] expected the status of the newest execution %v", t.Name(), test.name, publisher.states)
+		}
+		logMetrics(result)
+	}
+}
+
+// TestReconcileTimeBudget will test reconcile() stops when the time budget is spent
+func TestReconcileTimeBudget(t *testing.T) {
+
+	setConfig(t, func(c *loadedConfiguration) {
+		c.ReconcilePipelines = []string{"backfill", "backfill-retry"}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	publisher := &mockPublisher{name: publisherGitHub}
+	result, err := reconcile(ctx, &mockCodePipelineClient{}, publisher)
+	if err != nil {
+		t.Fatal("error occurred", err.Error())
+	} else if result.Unfinished != 2 || result.Checked != 0 || publisher.published != 0 {
+		t.Fatal("expected the pipelines to be unfinished", result.Unfinished, result.Checked, publisher.published)
+	}
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"gopkg.in/yaml.v3"
+)
+
+// pipelineStates are the CodePipeline states of a route state mapping (events and execution statuses)
+var pipelineStates = map[string]string{
+	"ABANDONED":  "ABANDONED",
+	"CANCELED":   "CANCELED",
+	"CANCELLED":  "CANCELED",
+	"FAILED":     "FAILED",
+	"INPROGRESS": "STARTED",
+	"RESUMED":    "RESUMED",
+	"STARTED":    "STARTED",
+	"STOPPED":    "STOPPED",
+	"STOPPING":   "STOPPING",
+	"SUCCEEDED":  "SUCCEEDED",
+	"SUPERSEDED": "SUPERSEDED",
+}
+
+// routingConfig is the routing configuration (ROUTING_CONFIG), YAML or JSON
+type routingConfig struct {
+	Routes []*pipelineRoute `yaml:"routes"`
+}
+
+// pipelineRoute is the configuration for the pipelines matching a glob (pipeline) or a regex (pipeline_regex)
+// Empty settings fall back to the global configuration
+type pipelineRoute struct {
+	Context             string            `yaml:"context"`    // Template (IE: ci/{{.Pipeline}})
+	Credential          *routeCredential  `yaml:"credential"` // GitHub token
+	EventKinds          []string          `yaml:"event_kinds"`
+	Name                string            `yaml:"name"`
+	Pipeline            string            `yaml:"pipeline"`
+	PipelineRegex       string            `yaml:"pipeline_regex"`
+	Publishers          []string          `yaml:"publishers"`
+	SourceArtifactNames []string          `yaml:"source_artifact_names"`
+	States              map[string]string `yaml:"states"` // CodePipeline state: GitHub state
+
+	contextTemplate *template.Template
+	credentials     credentialProvider
+	pattern         *regexp.Regexp
+}
+
+// routeCredential is the GitHub token of a route (KMS encrypted, SSM parameter or Secrets Manager secret)
+type routeCredential struct {
+	EncryptedToken string `yaml:"encrypted_token"`
+	Parameter      string `yaml:"parameter"`
+	SecretARN      string `yaml:"secret_arn"`
+	SecretKey      string `yaml:"secret_key"` // Key of a JSON secret (optional)
+}
+
+// routeContext is the data of a context template
+type routeContext struct {
+	Action   string
+	Context  string // Default context of the event
+	Kind     string
+	Pipeline string
+	Stage    string
+}
+
+// loadRoutingConfig will read and validate the routing configuration from a file (bundled) or S3 (s3://bucket/key)
+func loadRoutingConfig(cfg *configuration, location string, kmsSvc kmsiface.KMSAPI,
+	s3Svc s3iface.S3API,
+) ([]*pipelineRoute, error) {
+	if len(location) == 0 {
+		return nil, nil
+	}
+
+	// Read the configuration
+	data, err := readRoutingConfig(location, s3Svc)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read ROUTING_CONFIG %s: %w", location, err)
+	}
+
+	// Decode (YAML or JSON, unknown fields are rejected)
+	var routing routingConfig
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err = decoder.Decode(&routing); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid ROUTING_CONFIG %s: %w", location, err)
+	} else if len(routing.Routes) == 0 {
+		return nil, fmt.Errorf("invalid ROUTING_CONFIG %s: missing routes", location)
+	}
+
+	// Validate the routes
+	for i, route := range routing.Routes {
+		if route == nil {
+			return nil, fmt.Errorf("invalid ROUTING_CONFIG %s: empty route %d", location, i+1)
+		}
+		if err = validateRoute(cfg, route, kmsSvc); err != nil {
+			name := route.Name
+			if len(name) == 0 {
+				name = fmt.Sprintf("%d", i+1)
+			}
+			return nil, fmt.Errorf("invalid ROUTING_CONFIG %s: route %s: %w", location, name, err)
+		}
+	}
+
+	log.Printf("loaded %d pipeline routes from: %s", len(routing.Routes), location)
+	return routing.Routes, nil
+}
+
+// readRoutingConfig will read the routing configuration from a file or S3 (s3://bucket/key)
+func readRoutingConfig(location string, s3Svc s3iface.S3API) ([]byte, error) {
+	if !strings.HasPrefix(location, "s3://") {
+		return os.ReadFile(location)
+	}
+
+	// Read the S3 object
+	locationURL, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	} else if len(locationURL.Host) == 0 || len(strings.TrimPrefix(locationURL.Path, "/")) == 0 {
+		return nil, errors.New("expected s3://bucket/key")
+	}
+	if s3Svc == nil {
+		s3Svc = s3.New(awsSession)
+	}
+	var output *s3.GetObjectOutput
+	if output, err = s3Svc.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(locationURL.Host),
+		Key:    aws.String(strings.TrimPrefix(locationURL.Path, "/")),
+	}); err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = output.Body.Close()
+	}()
+	return io.ReadAll(output.Body)
+}
+
+// validateRoute will check the settings of a route and prepare the matcher, context template and credential
+func validateRoute(cfg *configuration, route *pipelineRoute, kmsSvc kmsiface.KMSAPI) (err error) {
+
+	// Pipeline glob or regex
+	switch {
+	case len(route.Pipeline) > 0 && len(route.PipelineRegex) > 0:
+		return errors.New("set either pipeline or pipeline_regex")
+	case len(route.Pipeline) > 0:
+		if _, err = path.Match(route.Pipeline, ""); err != nil {
+			return fmt.Errorf("invalid pipeline glob %s: %w", route.Pipeline, err)
+		}
+	case len(route.PipelineRegex) > 0:
+		if route.pattern, err = regexp.Compile(route.PipelineRegex); err != nil {
+			return fmt.Errorf("invalid pipeline_regex %s: %w", route.PipelineRegex, err)
+		}
+	default:
+		return errors.New("missing pipeline or pipeline_regex")
+	}
+
+	// Context template (must render with sample data)
+	if len(route.Context) > 0 {
+		if route.contextTemplate, err = template.New("context").Option("missingkey=error").Parse(route.Context); err != nil {
+			return fmt.Errorf("invalid context: %w", err)
+		}
+		var context string
+		if context, err = route.getContext(&routeContext{
+			Action: "Action", Context: githubContext, Kind: eventKindAction, Pipeline: "pipeline", Stage: "Stage",
+		}); err != nil {
+			return fmt.Errorf("invalid context: %w", err)
+		} else if len(context) == 0 {
+			return errors.New("invalid context: renders empty")
+		}
+	}
+
+	// Event kinds
+	for _, kind := range route.EventKinds {
+		if !isKnownEventKind(strings.TrimSpace(kind)) {
+			return fmt.Errorf("unknown event kind: %s", kind)
+		}
+	}
+
+	// State mapping (normalized to the event states)
+	states := make(map[string]string, len(route.States))
+	for pipelineState, state := range route.States {
+		normalized := normalizePipelineState(pipelineState)
+		if len(normalized) == 0 {
+			return fmt.Errorf("unknown pipeline state: %s", pipelineState)
+		} else if !isGitHubState(state) {
+			return fmt.Errorf("invalid state: %s for pipeline state: %s", state, pipelineState)
+		}
+		states[normalized] = state
+	}
+	route.States = states
+
+	// Source artifact names
+	for _, name := range route.SourceArtifactNames {
+		if len(strings.TrimSpace(name)) == 0 {
+			return errors.New("empty source artifact name")
+		}
+	}
+
+	// Publishers
+	if _, err = newPublishers(cfg, nil, route.Publishers); err != nil {
+		return
+	}
+
+	// Credential (decrypted or read on first use)
+	if route.Credential != nil {
+		route.credentials, err = newRouteCredentials(cfg, route.Credential, kmsSvc)
+	}
+	return
+}
+
+// newRouteCredentials will return the provider for the credential of a route (each with its own token cache)
+func newRouteCredentials(cfg *configuration, credential *routeCredential,
+	kmsSvc kmsiface.KMSAPI,
+) (credentialProvider, error) {
+	var providers []credentialProvider
+	if len(credential.EncryptedToken) > 0 {
+		providers = append(providers, &kmsCredentials{config: cfg, encryptedToken: credential.EncryptedToken, kmsSvc: kmsSvc})
+	}
+	if len(credential.Parameter) > 0 {
+		providers = append(providers, &ssmCredentials{
+			cache: &cachedToken{}, parameterName: credential.Parameter, ttl: cfg.GithubTokenTTL,
+		})
+	}
+	if len(credential.SecretARN) > 0 {
+		providers = append(providers, &secretsManagerCredentials{
+			cache: &cachedToken{}, key: credential.SecretKey, secretID: credential.SecretARN, ttl: cfg.GithubTokenTTL,
+		})
+	}
+	if len(providers) != 1 {
+		return nil, errors.New("credential requires one of: encrypted_token, parameter or secret_arn")
+	}
+	return providers[0], nil
+}
+
+// normalizePipelineState will return the event state of a CodePipeline state (IE: InProgress is STARTED)
+// Returns empty if the state is unknown
+func normalizePipelineState(state string) string {
+	return pipelineStates[strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(state), "_", ""))]
+}
+
+// matches will return true if the pipeline name matches the route
+func (r *pipelineRoute) matches(pipelineName string) bool {
+	if r.pattern != nil {
+		return r.pattern.MatchString(pipelineName)
+	}
+	matched, _ := path.Match(r.Pipeline, pipelineName)
+	return matched
+}
+
+// getContext will render the context template of the route
+func (r *pipelineRoute) getContext(data *routeContext) (string, error) {
+	var b strings.Builder
+	if err := r.contextTemplate.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// getPipelineRoute will return the first route matching the pipeline (nil if none or no pipeline)
+func getPipelineRoute(pipelineName string) *pipelineRoute {
+	if len(pipelineName) == 0 {
+		return nil
+	}
+	for _, route := range getConfig().routes {
+		if route.matches(pipelineName) {
+			return route
+		}
+	}
+	return nil
+}
+
+// getRouteCredentials will return the credential of the pipeline route (nil uses the configured provider)
+func getRouteCredentials(pipelineName string) credentialProvider {
+	if route := getPipelineRoute(pipelineName); route != nil {
+		return route.credentials
+	}
+	return nil
+}
+
+// getRouteState will return the GitHub state of the pipeline state from the route (or the given state)
+func getRouteState(pipelineName, pipelineState, state string) string {
+	if route := getPipelineRoute(pipelineName); route != nil {
+		if mapped, ok := route.States[normalizePipelineState(pipelineState)]; ok {
+			return mapped
+		}
+	}
+	return state
+}
+
+// applyRouteContext will set the context of the update from the template of the pipeline route
+// The default context is kept if the template fails
+func applyRouteContext(update *statusUpdate, kind, stage, action string) {
+	route := getPipelineRoute(update.Pipeline)
+	if route == nil || route.contextTemplate == nil {
+		return
+	}
+	context, err := route.getContext(&routeContext{
+		Action: action, Context: update.Context, Kind: kind, Pipeline: update.Pipeline, Stage: stage,
+	})
+	if err != nil || len(context) == 0 {
+		log.Printf("unable to render the context of pipeline: %s error: %v", update.Pipeline, err)
+		return
+	}
+	update.Context = context
+}
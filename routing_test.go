@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// testRoutingConfig is a valid routing configuration (YAML)
+const testRoutingConfig = `
+routes:
+  - name: team-a
+    pipeline: "team-a-*"
+    context: "ci/{{.Pipeline}}{{with .Stage}}/{{.}}{{end}}"
+    credential:
+      secret_arn: arn:aws:secretsmanager:us-east-1:123456789012:secret:team-a
+    event_kinds: [pipeline, stage]
+    states:
+      Stopped: error
+      SUPERSEDED: success
+    source_artifact_names: [AppSource]
+    publishers: [github]
+  - pipeline_regex: "^team-(a|b)-.*$"
+    event_kinds: [pipeline]
+`
+
+// Mocking s3 client
+type mockS3Client struct {
+	s3iface.S3API
+	objects map[string]string // bucket/key: body
+}
+
+// GetObject is a mock request for s3
+func (m *mockS3Client) GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	body, ok := m.objects[aws.StringValue(input.Bucket)+"/"+aws.StringValue(input.Key)]
+	if !ok {
+		return nil, errors.New("aws will reject: no such key")
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(body))}, nil
+}
+
+// writeRoutingConfig will write a routing configuration to a temporary file and return the path
+func writeRoutingConfig(t *testing.T, data string) string {
+	location := filepath.Join(t.TempDir(), "routing.yaml")
+	if err := os.WriteFile(location, []byte(data), 0o600); err != nil {
+		t.Fatal("error occurred", err.Error())
+	}
+	return location
+}
+
+// TestLoadRoutingConfig will test loadRoutingConfig() and the validation of the routes
+func TestLoadRoutingConfig(t *testing.T) {
+
+	// Disabled
+	if routes, err := loadRoutingConfig(&configuration{}, "", &mockKmsClient{}, nil); err != nil || routes != nil {
+		t.Fatal("expected no routes", routes, err)
+	}
+
+	var tests = []struct {
+		name          string
+		data          string
+		expectedError bool
+	}{
+		{"yaml", testRoutingConfig, false},
+		{"json", `{"routes":[{"pipeline":"app-*","publishers":["github"],"credential":{"parameter":"/app/github_token"}}]}`, false},
+		{"kms credential", "routes:\n  - pipeline: app\n    credential: {encrypted_token: ZW5jcnlwdGVk}", false},
+		{"empty", "", true},
+		{"no routes", "routes: []", true},
+		{"unknown field", "routes:\n  - pipeline: app\n    contexts: ci", true},
+		{"missing match", "routes:\n  - context: ci", true},
+		{"glob and regex", "routes:\n  - pipeline: app\n    pipeline_regex: app", true},
+		{"invalid glob", "routes:\n  - pipeline: \"app-[\"", true},
+		{"invalid regex", "routes:\n  - pipeline_regex: \"app-(\"", true},
+		{"invalid template", "routes:\n  - pipeline: app\n    context: \"ci/{{.Pipeline\"", true},
+		{"unknown template field", "routes:\n  - pipeline: app\n    context: \"ci/{{.Repository}}\"", true},
+		{"empty template", "routes:\n  - pipeline: app\n    context: \"{{if false}}ci{{end}}\"", true},
+		{"unknown event kind", "routes:\n  - pipeline: app\n    event_kinds: [release]", true},
+		{"unknown pipeline state", "routes:\n  - pipeline: app\n    states: {DONE: success}", true},
+		{"invalid state", "routes:\n  - pipeline: app\n    states: {FAILED: broken}", true},
+		{"empty artifact name", "routes:\n  - pipeline: app\n    source_artifact_names: [\" \"]", true},
+		{"unknown publisher", "routes:\n  - pipeline: app\n    publishers: [pager]", true},
+		{"credential without token", "routes:\n  - pipeline: app\n    credential: {secret_key: token}", true},
+		{"credential with two tokens", "routes:\n  - pipeline: app\n    credential: {parameter: /app/token, secret_arn: arn:secret}", true},
+	}
+
+	for _, test := range tests {
+		routes, err := loadRoutingConfig(&configuration{}, writeRoutingConfig(t, test.data), &mockKmsClient{}, nil)
+		if err == nil && test.expectedError {
+			t.Errorf("%s Failed: [%s] expected to throw an error, but no error", t.Name(), test.name)
+		} else if err != nil && !test.expectedError {
+			t.Errorf("%s Failed: [%s] error occurred [%s]", t.Name(), test.name, err.Error())
+		} else if err == nil && len(routes) == 0 {
+			t.Errorf("%s Failed: [%s] expected routes", t.Name(), test.name)
+		}
+	}
+
+	// Missing file
+	if _, err := loadRoutingConfig(&configuration{}, filepath.Join(t.TempDir(), "missing.yaml"), &mockKmsClient{}, nil); err == nil {
+		t.Fatal("error should have occurred")
+	}
+}
+
+// TestReadRoutingConfig will test readRoutingConfig() from S3
+func TestReadRoutingConfig(t *testing.T) {
+	t.Parallel()
+
+	mockS3 := &mockS3Client{objects: map[string]string{"config-bucket/routing/routes.yaml": testRoutingConfig}}
+
+	var tests = []struct {
+		location      string
+		expectedError bool
+	}{
+		{"s3://config-bucket/routing/routes.yaml", false},
+		{"s3://config-bucket/routing/missing.yaml", true},
+		{"s3://config-bucket", true},
+		{"s3:///routes.yaml", true},
+	}
+
+	for _, test := range tests {
+		data, err := readRoutingConfig(test.location, mockS3)
+		if err == nil && test.expectedError {
+			t.Errorf("%s Failed: [%s] expected to throw an error, but no error", t.Name(), test.location)
+		} else if err != nil && !test.expectedError {
+			t.Errorf("%s Failed: [%s] error occurred [%s]", t.Name(), test.location, err.Error())
+		} else if err == nil && string(data) != testRoutingConfig {
+			t.Errorf("%s Failed: [%s] configuration was not as expected", t.Name(), test.location)
+		}
+	}
+}
+
+// TestPipelineRoutes will test the settings of the matching route and the fallbacks to the configuration
+func TestPipelineRoutes(t *testing.T) {
+
+	routes, err := loadRoutingConfig(&configuration{}, writeRoutingConfig(t, testRoutingConfig), &mockKmsClient{}, nil)
+	if err != nil {
+		t.Fatal("error occurred", err.Error())
+	}
+	setConfig(t, func(c *loadedConfiguration) {
+		c.routes = routes
+		c.EventKinds = []string{"pipeline", "stage", "action"}
+		c.Publishers = []string{"github", "codecommit"}
+		c.SourceArtifactNames = []string{"SourceCode"}
+	})
+
+	// Matching (first match wins)
+	if route := getPipelineRoute("team-a-api"); route == nil || route.Name != "team-a" {
+		t.Fatal("expected the team-a route", route)
+	} else if route = getPipelineRoute("team-b-api"); route == nil || route.PipelineRegex == "" {
+		t.Fatal("expected the regex route", route)
+	} else if route = getPipelineRoute("team-c-api"); route != nil {
+		t.Fatal("expected no route", route)
+	} else if route = getPipelineRoute(""); route != nil {
+		t.Fatal("expected no route without a pipeline", route)
+	}
+
+	// Event kinds
+	if !isEventKindEnabled(eventKindStage, "team-a-api") || isEventKindEnabled(eventKindStage, "team-b-api") {
+		t.Fatal("expected the event kinds of the routes")
+	} else if !isEventKindEnabled(eventKindAction, "team-c-api") || isEventKindEnabled(eventKindAction, "team-a-api") {
+		t.Fatal("expected the configured event kinds without a route")
+	}
+
+	// Source artifact names
+	if names := getSourceArtifactNames("team-a-api"); len(names) != 1 || names[0] != "AppSource" {
+		t.Fatal("expected the artifact names of the route", names)
+	} else if names = getSourceArtifactNames("team-b-api"); len(names) != 1 || names[0] != "SourceCode" {
+		t.Fatal("expected the configured artifact names", names)
+	}
+
+	// Publishers
+	if publishers, err := getPublishers(nil, "team-a-api"); err != nil || len(publishers) != 1 {
+		t.Fatal("expected the publishers of the route", len(publishers), err)
+	} else if publishers, err = getPublishers(nil, "team-b-api"); err != nil || len(publishers) != 2 {
+		t.Fatal("expected the configured publishers", len(publishers), err)
+	}
+
+	// States
+	var tests = []struct {
+		pipelineName  string
+		pipelineState string
+		state         string
+		expectedState string
+	}{
+		{"team-a-api", "Stopped", "failure", "error"},
+		{"team-a-api", "STOPPED", "failure", "error"},
+		{"team-a-api", "Superseded", "failure", "success"},
+		{"team-a-api", "FAILED", "failure", "failure"},
+		{"team-b-api", "STOPPED", "failure", "failure"},
+		{"team-c-api", "STOPPED", "failure", "failure"},
+	}
+	for _, test := range tests {
+		if state := getRouteState(test.pipelineName, test.pipelineState, test.state); state != test.expectedState {
+			t.Errorf("%s Failed: [%s/%s] expected [%s] got [%s]", t.Name(), test.pipelineName, test.pipelineState, test.expectedState, state)
+		}
+	}
+
+	// Context template
+	update := &statusUpdate{Context: githubContext + "/Build", Pipeline: "team-a-api"}
+	if applyRouteContext(update, eventKindStage, "Build", ""); update.Context != "ci/team-a-api/Build" {
+		t.Fatal("context was not as expected", update.Context)
+	}
+	update = &statusUpdate{Context: githubContext, Pipeline: "team-b-api"}
+	if applyRouteContext(update, eventKindPipeline, "", ""); update.Context != githubContext {
+		t.Fatal("expected the default context", update.Context)
+	}
+
+	// Credentials
+	if provider := getRouteCredentials("team-a-api"); provider == nil || provider.Name() != credentialProviderSecretsManager {
+		t.Fatal("expected the credential of the route", provider)
+	} else if provider = getRouteCredentials("team-b-api"); provider != nil {
+		t.Fatal("expected the configured credential", provider)
+	}
+}
+
+// TestRouteCredentials will test githubRequest() with the credential of a route (context)
+func TestRouteCredentials(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token team-a-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	routes, err := loadRoutingConfig(&configuration{}, writeRoutingConfig(t, testRoutingConfig), &mockKmsClient{}, nil)
+	if err != nil {
+		t.Fatal("error occurred", err.Error())
+	}
+	routes[0].credentials.(*secretsManagerCredentials).secretsManagerSvc = &mockSecretsManagerClient{tokens: []string{"team-a-token"}}
+	setConfig(t, func(c *loadedConfiguration) {
+		c.routes = routes
+		c.credentials = &envCredentials{}
+		c.GithubAccessToken = "global-token"
+		c.GithubAPIURL = server.URL
+	})
+
+	// Credential of the route
+	ctx := withCredentials(context.Background(), getRouteCredentials("team-a-api"))
+	if err = postCommitStatus(ctx, "owner", "repo", "sha", &payload{}); err != nil {
+		t.Fatal("error occurred", err.Error())
+	}
+
+	// Configured credential (no route credential)
+	ctx = withCredentials(context.Background(), getRouteCredentials("team-b-api"))
+	var ghErr *githubError
+	if err = postCommitStatus(ctx, "owner", "repo", "sha", &payload{}); !errors.As(err, &ghErr) || ghErr.StatusCode != http.StatusUnauthorized {
+		t.Fatal("expected the configured token to be used", err)
+	}
+}
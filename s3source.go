@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/codepipeline"
+	"github.com/aws/aws-sdk-go/service/codepipeline/codepipelineiface"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// S3 source defaults
+const (
+	s3RevisionSummaryKey = "codepipeline-artifact-revision-summary"
+	s3SourceCategory     = "Source"
+	s3SourceProvider     = "S3"
+)
+
+// getS3Revision will resolve the commit for an S3 source artifact (pushed by an external CI)
+//
+// The commit is read from the revision summary (codepipeline-artifact-revision-summary JSON)
+// and if missing, from the metadata on the S3 object version (revision summary JSON or metadata keys)
+// Returns nil if the artifact is not from an S3 source action
+func getS3Revision(pipelineName string, artifact *codepipeline.ArtifactRevision,
+	pipeline codepipelineiface.CodePipelineAPI, s3Svc s3iface.S3API,
+) (revision *sourceRevision, err error) {
+
+	// Commit info from the revision summary (set from the object metadata by CodePipeline)
+	if revision = getMetadataRevision(getRevisionSummary(aws.StringValue(artifact.RevisionSummary))); revision != nil {
+		revision.ArtifactName = aws.StringValue(artifact.Name)
+		return
+	}
+
+	// Find the S3 object for the artifact
+	var bucket, key string
+	if bucket, key, err = getS3SourceLocation(pipelineName, aws.StringValue(artifact.Name), pipeline); err != nil {
+		return
+	} else if len(bucket) == 0 {
+		return
+	}
+
+	// Get the commit from the object version metadata
+	if revision, err = getS3ObjectRevision(s3Svc, bucket, key, aws.StringValue(artifact.RevisionId)); err != nil {
+		return
+	}
+	revision.ArtifactName = aws.StringValue(artifact.Name)
+	return
+}
+
+// isS3SourceArtifact will return true if the artifact is from an S3 source action
+// (commit metadata in the revision summary, or an output of an S3 source action of the pipeline)
+func isS3SourceArtifact(pipelineName string, artifact *codepipeline.ArtifactRevision,
+	pipeline codepipelineiface.CodePipelineAPI,
+) bool {
+	if getMetadataRevision(getRevisionSummary(aws.StringValue(artifact.RevisionSummary))) != nil {
+		return true
+	}
+	bucket, _, err := getS3SourceLocation(pipelineName, aws.StringValue(artifact.Name), pipeline)
+	if err != nil {
+		log.Printf("unable to find the source action of artifact: %s for pipeline: %s error: %s",
+			aws.StringValue(artifact.Name), pipelineName, err.Error())
+		return false
+	}
+	return len(bucket) > 0
+}
+
+// getS3ObjectRevision will resolve the commit from the metadata on an S3 object (version)
+// using the revision summary (JSON) or the individual metadata keys
+func getS3ObjectRevision(s3Svc s3iface.S3API, bucket, key, versionID string) (revision *sourceRevision, err error) {
+
+	// Get the metadata from the object version
+	var output *s3.HeadObjectOutput
+	input := &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}
+	if len(versionID) > 0 {
+		input.VersionId = aws.String(versionID)
+	}
+	if output, err = s3Svc.HeadObject(input); err != nil {
+		return
+	}
+
+	// Metadata keys are case-insensitive (canonical headers)
+	metadata := make(map[string]string, len(output.Metadata))
+	for name, value := range output.Metadata {
+		metadata[strings.ToLower(name)] = aws.StringValue(value)
+	}
+
+	// Revision summary (JSON) or individual metadata keys
+	if revision = getMetadataRevision(getRevisionSummary(metadata[s3RevisionSummaryKey])); revision == nil {
+		revision = getMetadataRevision(metadata)
+	}
+	if revision == nil {
+		err = fmt.Errorf("unable to find commit metadata on s3://%s/%s", bucket, key)
+	}
+	return
+}
+
+// getRevisionSummary will parse the revision summary JSON into lowercase keys (string values only)
+func getRevisionSummary(summary string) map[string]string {
+	var values map[string]interface{}
+	if err := json.Unmarshal([]byte(summary), &values); err != nil {
+		return nil
+	}
+	metadata := make(map[string]string, len(values))
+	for name, value := range values {
+		if s, ok := value.(string); ok {
+			metadata[strings.ToLower(name)] = s
+		}
+	}
+	return metadata
+}
+
+// getMetadataRevision will return the revision using the configured metadata keys (repository and commit)
+func getMetadataRevision(metadata map[string]string) *sourceRevision {
+	cfg := getConfig()
+	repository := metadata[strings.ToLower(cfg.S3MetadataRepositoryKey)]
+	commit := metadata[strings.ToLower(cfg.S3MetadataCommitKey)]
+	if len(repository) == 0 || len(commit) == 0 {
+		return nil
+	}
+	return &sourceRevision{
+		Commit:     commit,
+		Repository: repository,
+	}
+}
+
+// getS3SourceLocation will return the bucket and object key of the S3 source action for an artifact
+func getS3SourceLocation(pipelineName, artifactName string,
+	pipeline codepipelineiface.CodePipelineAPI,
+) (bucket, key string, err error) {
+
+	// Get the pipeline declaration
+	var output *codepipeline.GetPipelineOutput
+	if output, err = pipeline.GetPipeline(&codepipeline.GetPipelineInput{
+		Name: aws.String(pipelineName),
+	}); err != nil {
+		return
+	} else if output == nil || output.Pipeline == nil {
+		err = fmt.Errorf("missing pipeline: %s", pipelineName)
+		return
+	}
+
+	// Find the S3 source action with the output artifact
+	for _, stage := range output.Pipeline.Stages {
+		for _, action := range stage.Actions {
+			if action.ActionTypeId == nil ||
+				aws.StringValue(action.ActionTypeId.Category) != s3SourceCategory ||
+				aws.StringValue(action.ActionTypeId.Provider) != s3SourceProvider {
+				continue
+			}
+			for _, outputArtifact := range action.OutputArtifacts {
+				if aws.StringValue(outputArtifact.Name) == artifactName {
+					bucket = aws.StringValue(action.Configuration["S3Bucket"])
+					key = aws.StringValue(action.Configuration["S3ObjectKey"])
+					return
+				}
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// newS3StandIn will start a local S3-compatible server (HeadObject only) and return a client using it
+func newS3StandIn(t *testing.T) *s3.S3 {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead || r.URL.Path != "/artifact-bucket/app/source.zip" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch r.URL.Query().Get("versionId") {
+		case "version-metadata":
+			w.Header().Set("X-Amz-Meta-Repository", "https://github.com/mrz1836/codepipeline-to-github.git")
+			w.Header().Set("X-Amz-Meta-Commit", "25c0c3e61c4db2c2cde8b163b3ad096875c1ce08")
+		case "version-summary":
+			w.Header().Set("X-Amz-Meta-Codepipeline-Artifact-Revision-Summary",
+				`{"repository":"mrz1836/codepipeline-to-github","commit":"25c0c3e61c4db2c2cde8b163b3ad096875c1ce08"}`)
+		case "version-empty":
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+
+	return s3.New(session.Must(session.NewSession(&aws.Config{
+		Credentials:      credentials.NewStaticCredentials("test-id", "test-secret", ""),
+		Endpoint:         aws.String(server.URL),
+		Region:           aws.String("us-east-1"),
+		S3ForcePathStyle: aws.Bool(true),
+	})))
+}
+
+// TestGetS3Revision will test getS3Revision() against a local S3 stand-in
+func TestGetS3Revision(t *testing.T) {
+
+	setConfig(t, func(c *loadedConfiguration) {
+		c.S3MetadataCommitKey = "commit"
+		c.S3MetadataRepositoryKey = "repository"
+	})
+
+	mockPipeline := &mockCodePipelineClient{}
+	s3Svc := newS3StandIn(t)
+
+	var tests = []struct {
+		pipelineName       string
+		executionID        string
+		expectedRepository string
+		expectedError      bool
+	}{
+		{"s3-source-summary", "12345", "mrz1836/codepipeline-to-github", false},
+		{"s3-source", "version-metadata", "https://github.com/mrz1836/codepipeline-to-github.git", false},
+		{"s3-source", "version-summary", "mrz1836/codepipeline-to-github", false},
+		{"s3-source", "version-empty", "", true},
+		{"s3-source", "version-missing", "", true},
+	}
+
+	for _, test := range tests {
+		revisions, _, err := getRevisions(test.pipelineName, test.executionID, mockPipeline, s3Svc)
+		if err == nil && test.expectedError {
+			t.Errorf("%s Failed: [%s] [%s] expected to throw an error, but no error", t.Name(), test.pipelineName, test.executionID)
+		} else if err != nil && !test.expectedError {
+			t.Errorf("%s Failed: [%s] [%s] error occurred [%s]", t.Name(), test.pipelineName, test.executionID, err.Error())
+		} else if !test.expectedError && (len(revisions) != 1 || revisions[0].Repository != test.expectedRepository ||
+			revisions[0].Commit != "25c0c3e61c4db2c2cde8b163b3ad096875c1ce08" || revisions[0].ArtifactName != "SourceCode") {
+			t.Errorf("%s Failed: [%s] [%s] revision was not as expected", t.Name(), test.pipelineName, test.executionID)
+		}
+	}
+}
+
+// TestGetRevisionsS3MultiSource will test the S3 source artifact of a multi-source pipeline
+func TestGetRevisionsS3MultiSource(t *testing.T) {
+
+	setConfig(t, func(c *loadedConfiguration) {
+		c.AllSourceArtifacts = true
+		c.S3MetadataCommitKey = "commit"
+		c.S3MetadataRepositoryKey = "repository"
+	})
+
+	mockPipeline := &mockCodePipelineClient{}
+	s3Svc := newS3StandIn(t)
+
+	// Every source artifact (skips the artifact that is not from a source action)
+	revisions, _, err := getRevisions("s3-source-multi", "version-summary", mockPipeline, s3Svc)
+	if err != nil {
+		t.Fatal("error occurred", err.Error())
+	} else if len(revisions) != 2 {
+		t.Fatal("expected 2 revisions", len(revisions))
+	} else if revisions[0].ArtifactName != "SourceCode" || revisions[0].Repository != "mrz1836/codepipeline-to-github" ||
+		revisions[0].Commit != "25c0c3e61c4db2c2cde8b163b3ad096875c1ce08" {
+		t.Fatal("expected the commit of the S3 source artifact", revisions[0])
+	} else if revisions[1].ArtifactName != "AppSource" || revisions[1].Commit != "9b2a1e4f5c6d7e8f9a0b1c2d3e4f5a6b7c8d9e0f" {
+		t.Fatal("expected the commit of the GitHub source artifact", revisions[1])
+	}
+
+	// Auto-detect the S3 source artifact
+	setConfig(t, func(c *loadedConfiguration) {
+		c.AllSourceArtifacts = false
+		c.SourceArtifactAutoDetect = true
+		c.SourceArtifactNames = []string{"Missing"}
+	})
+	if revisions, _, err = getRevisions("s3-source-multi", "version-metadata", mockPipeline, s3Svc); err != nil {
+		t.Fatal("error occurred", err.Error())
+	} else if len(revisions) != 1 || revisions[0].ArtifactName != "SourceCode" ||
+		revisions[0].Repository != "https://github.com/mrz1836/codepipeline-to-github.git" {
+		t.Fatal("expected the S3 source artifact", revisions)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+)
+
+// secretsManagerCredentials is the GitHub token read from Secrets Manager at runtime (GITHUB_TOKEN_SECRET_ARN)
+// Rotations are picked up without a redeploy (cached for the TTL and refreshed after a 401)
+type secretsManagerCredentials struct {
+	cache             *cachedToken // Defaults to githubTokenCache
+	key               string       // Key of a JSON secret (optional)
+	secretID          string
+	secretsManagerSvc secretsmanageriface.SecretsManagerAPI
+	ttl               time.Duration // GITHUB_TOKEN_TTL (zero is the default)
+}
+
+// Name will return the provider name
+func (c *secretsManagerCredentials) Name() string {
+	return credentialProviderSecretsManager
+}
+
+// Token will return the cached token, or read the secret
+func (c *secretsManagerCredentials) Token(refresh bool) (string, error) {
+	return getTokenCache(c.cache).get(credentialProviderSecretsManager+":"+c.secretID, c.ttl, refresh, func() (string, error) {
+		if c.secretsManagerSvc == nil {
+			c.secretsManagerSvc = secretsmanager.New(awsSession)
+		}
+		return getSecretValue(c.secretsManagerSvc, c.secretID, c.key)
+	})
+}
+
+// getSecretValue will read the (current) value of a secret, or a key of a JSON secret
+func getSecretValue(secretsManagerSvc secretsmanageriface.SecretsManagerAPI, secretID, key string) (string, error) {
+	output, err := secretsManagerSvc.GetSecretValue(&secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(secretID),
+	})
+	if err != nil {
+		return "", err
+	} else if output == nil {
+		return "", fmt.Errorf("missing secret value: %s", secretID)
+	}
+
+	// String or binary secret
+	value := aws.StringValue(output.SecretString)
+	if output.SecretString == nil {
+		value = string(output.SecretBinary)
+	}
+
+	// Key of a JSON secret (IE: {"github_personal_token":"..."})
+	if len(key) > 0 {
+		var values map[string]string
+		if err = json.Unmarshal([]byte(value), &values); err != nil {
+			return "", fmt.Errorf("secret %s is not a JSON object: %w", secretID, err)
+		}
+		value = values[key]
+	}
+
+	if value = strings.TrimSpace(value); len(value) == 0 {
+		return "", errors.New("missing token in secret: " + secretID)
+	}
+	return value, nil
+}
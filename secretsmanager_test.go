@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+)
+
+// Mocking secrets manager client
+type mockSecretsManagerClient struct {
+	secretsmanageriface.SecretsManagerAPI
+	calls  int
+	tokens []string // Returned in order (rotations), the last one is repeated
+}
+
+// GetSecretValue is a mock request for secrets manager
+func (m *mockSecretsManagerClient) GetSecretValue(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+	switch aws.StringValue(input.SecretId) {
+	case "":
+		return nil, errors.New("aws will reject: missing secret id")
+	case "json-secret":
+		return &secretsmanager.GetSecretValueOutput{SecretString: aws.String(`{"github_personal_token":"json-token"}`)}, nil
+	case "binary-secret":
+		return &secretsmanager.GetSecretValueOutput{SecretBinary: []byte("binary-token\n")}, nil
+	case "empty-secret":
+		return &secretsmanager.GetSecretValueOutput{SecretString: aws.String(" ")}, nil
+	}
+	token := m.tokens[len(m.tokens)-1]
+	if m.calls < len(m.tokens) {
+		token = m.tokens[m.calls]
+	}
+	m.calls++
+	return &secretsmanager.GetSecretValueOutput{SecretString: aws.String(token)}, nil
+}
+
+// TestGetSecretValue will test getSecretValue()
+func TestGetSecretValue(t *testing.T) {
+	t.Parallel()
+
+	mockSecretsManager := &mockSecretsManagerClient{tokens: []string{"plain-token"}}
+
+	var tests = []struct {
+		secretID      string
+		key           string
+		expectedValue string
+		expectedError bool
+	}{
+		{"plain-secret", "", "plain-token", false},
+		{"json-secret", "github_personal_token", "json-token", false},
+		{"binary-secret", "", "binary-token", false},
+		{"json-secret", "missing-key", "", true},
+		{"plain-secret", "github_personal_token", "", true},
+		{"empty-secret", "", "", true},
+		{"", "", "", true},
+	}
+
+	for _, test := range tests {
+		value, err := getSecretValue(mockSecretsManager, test.secretID, test.key)
+		if err == nil && test.expectedError {
+			t.Errorf("%s Failed: [%s/%s] expected to throw an error, but no error", t.Name(), test.secretID, test.key)
+		} else if err != nil && !test.expectedError {
+			t.Errorf("%s Failed: [%s/%s] error occurred [%s]", t.Name(), test.secretID, test.key, err.Error())
+		} else if value != test.expectedValue {
+			t.Errorf("%s Failed: [%s/%s] expected [%s] got [%s]", t.Name(), test.secretID, test.key, test.expectedValue, value)
+		}
+	}
+}
+
+// TestSecretsManagerCredentials will test the provider and the token cache (TTL, refresh and a changed secret)
+func TestSecretsManagerCredentials(t *testing.T) {
+
+	previous := githubTokenCache
+	githubTokenCache = &cachedToken{}
+	defer func() {
+		githubTokenCache = previous
+	}()
+
+	mockSecretsManager := &mockSecretsManagerClient{tokens: []string{"token-1", "token-2", "token-3"}}
+	provider := &secretsManagerCredentials{
+		secretID:          "arn:aws:secretsmanager:us-east-1:123456789012:secret:github",
+		secretsManagerSvc: mockSecretsManager,
+		ttl:               time.Hour,
+	}
+	if provider.Name() != credentialProviderSecretsManager {
+		t.Fatal("provider name was not as expected", provider.Name())
+	}
+
+	if token, err := provider.Token(false); err != nil || token != "token-1" {
+		t.Fatal("expected the first token", token, err)
+	} else if token, err = provider.Token(false); err != nil || token != "token-1" || mockSecretsManager.calls != 1 {
+		t.Fatal("expected the cached token", token, err, mockSecretsManager.calls)
+	} else if token, err = provider.Token(true); err != nil || token != "token-2" {
+		t.Fatal("expected a refreshed token", token, err)
+	} else if time.Until(githubTokenCache.expires) <= defaultTokenTTL {
+		t.Fatal("expected the ttl of the provider", githubTokenCache.expires)
+	}
+
+	// Expired
+	githubTokenCache.expires = time.Now().Add(-time.Second)
+	if token, err := provider.Token(false); err != nil || token != "token-3" {
+		t.Fatal("expected a new token after the ttl", token, err)
+	}
+
+	// Another secret
+	other := &secretsManagerCredentials{key: "github_personal_token", secretID: "json-secret", secretsManagerSvc: mockSecretsManager}
+	if token, err := other.Token(false); err != nil || token != "json-token" {
+		t.Fatal("expected the token of the other secret", token, err)
+	}
+}
+
+// TestGithubRequestRefresh will test githubRequest() refreshing a rotated token after a 401
+func TestGithubRequestRefresh(t *testing.T) {
+
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("Authorization") != "token rotated-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	mockSecretsManager := &mockSecretsManagerClient{tokens: []string{"old-token", "rotated-token"}}
+	previousCache := githubTokenCache
+	githubTokenCache = &cachedToken{}
+	setConfig(t, func(c *loadedConfiguration) {
+		c.credentials = &secretsManagerCredentials{
+			secretID:          "arn:aws:secretsmanager:us-east-1:123456789012:secret:github",
+			secretsManagerSvc: mockSecretsManager,
+		}
+		c.GithubAPIURL = server.URL
+	})
+	defer func() {
+		githubTokenCache = previousCache
+	}()
+
+	if err := githubRequest(context.Background(), http.MethodPost, "/repos/owner/repo/statuses/sha", &payload{}, nil, http.StatusCreated); err != nil {
+		t.Fatal("error occurred", err.Error())
+	} else if requests != 2 || mockSecretsManager.calls != 2 {
+		t.Fatal("expected a single retry with the refreshed token", requests, mockSecretsManager.calls)
+	}
+
+	// Still unauthorized after the refresh (no loop)
+	mockSecretsManager.tokens = []string{"revoked-token", "other-revoked-token"}
+	mockSecretsManager.calls = 0
+	githubTokenCache.expires = time.Time{}
+	requests = 0
+	var ghErr *githubError
+	if err := githubRequest(context.Background(), http.MethodPost, "/repos/owner/repo/statuses/sha", &payload{}, nil, http.StatusCreated); !errors.As(err, &ghErr) {
+		t.Fatal("expected a GitHub error", err)
+	} else if ghErr.StatusCode != http.StatusUnauthorized || requests != 2 {
+		t.Fatal("expected a single retry", ghErr.StatusCode, requests)
+	}
+
+	// Same token after the refresh (no retry)
+	setConfig(t, func(c *loadedConfiguration) {
+		c.credentials = &envCredentials{}
+		c.GithubAccessToken = "env-token"
+	})
+	requests = 0
+	if err := githubRequest(context.Background(), http.MethodPost, "/repos/owner/repo/statuses/sha", &payload{}, nil, http.StatusCreated); !errors.As(err, &ghErr) {
+		t.Fatal("expected a GitHub error", err)
+	} else if requests != 1 {
+		t.Fatal("expected no retry with the same token", requests)
+	}
+}
+
+// TestLoadConfigurationSecret will test loadConfiguration() with the token in Secrets Manager (cached)
+func TestLoadConfigurationSecret(t *testing.T) {
+
+	const secretARN = "arn:aws:secretsmanager:us-east-1:123456789012:secret:github"
+	previous, previousConfiguration := githubTokenCache, currentConfiguration.Load()
+	githubTokenCache = &cachedToken{
+		expires: time.Now().Add(time.Hour),
+		source:  credentialProviderSecretsManager + ":" + secretARN,
+		token:   "secret-token",
+	}
+	defer func() {
+		githubTokenCache = previous
+		currentConfiguration.Store(previousConfiguration)
+	}()
+
+	os.Clearenv()
+	_ = os.Setenv("AWS_REGION", "us-east-1")
+	_ = os.Setenv("APPLICATION_STAGE_NAME", "production")
+	_ = os.Setenv("GITHUB_TOKEN_SECRET_ARN", secretARN)
+	defer func() {
+		_ = os.Unsetenv("GITHUB_TOKEN_SECRET_ARN")
+	}()
+
+	if err := loadConfiguration(&mockKmsClient{}); err != nil {
+		t.Fatal("error occurred", err.Error())
+	} else if cfg := getConfig(); cfg.GithubAccessToken != "secret-token" || cfg.credentials.Name() != credentialProviderSecretsManager {
+		t.Fatal("expected the token from the secret (not decrypted by KMS)", cfg.GithubAccessToken)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// snsEventSource is the event source of SNS records
+const snsEventSource = "aws:sns"
+
+// notification is the detail type of a CodePipeline notification rule (delivered via SNS, the rest is the event)
+type notification struct {
+	NotificationDetailType string `json:"detailType"` // Notification rules use camelCase
+}
+
+// getSNSRecords will return the SNS records if the payload is an SNS envelope (nil otherwise)
+func getSNSRecords(raw json.RawMessage) (records []events.SNSEventRecord) {
+	var snsEvent events.SNSEvent
+	if err := json.Unmarshal(raw, &snsEvent); err != nil {
+		return
+	}
+	for _, record := range snsEvent.Records {
+		if record.EventSource == snsEventSource {
+			records = append(records, record)
+		}
+	}
+	return
+}
+
+// processSNSRecords will unwrap and process every SNS record, all errors are returned together
+func processSNSRecords(records []events.SNSEventRecord) error {
+	var errs []error
+	for _, record := range records {
+		ev, err := getSNSEvent(record.SNS.Message)
+		if err == nil {
+			err = ProcessEvent(ev)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("sns message %s: %w", record.SNS.MessageID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// getSNSEvent will parse the event from an SNS message (JSON string)
+func getSNSEvent(message string) (ev event, err error) {
+	if err = json.Unmarshal([]byte(message), &ev); err != nil || len(ev.DetailType) > 0 {
+		return
+	}
+	var n notification
+	if err = json.Unmarshal([]byte(message), &n); err != nil {
+		return
+	}
+	ev.DetailType = n.NotificationDetailType
+	return
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// newSNSEnvelope will wrap the messages in an SNS event (one record per message)
+func newSNSEnvelope(t *testing.T, messages ...string) json.RawMessage {
+	type record struct {
+		EventSource string            `json:"EventSource"`
+		SNS         map[string]string `json:"Sns"`
+	}
+	var records []record
+	for i, message := range messages {
+		records = append(records, record{
+			EventSource: snsEventSource,
+			SNS:         map[string]string{"MessageId": "message-" + string(rune('a'+i)), "Message": message},
+		})
+	}
+	raw, err := json.Marshal(map[string]interface{}{"Records": records})
+	if err != nil {
+		t.Fatal("error occurred", err.Error())
+	}
+	return raw
+}
+
+// TestGetSNSEvent will test getSNSEvent()
+func TestGetSNSEvent(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		message            string
+		expectedDetailType string
+		expectedPipeline   string
+		expectedError      bool
+	}{
+		{`{"detailType":"CodePipeline Pipeline Execution State Change","detail":{"pipeline":"my-pipeline","execution-id":"12345","state":"SUCCEEDED"}}`, "CodePipeline Pipeline Execution State Change", "my-pipeline", false},
+		{`{"detail-type":"CodeBuild Build State Change","detail":{"project-name":"my-project"}}`, detailTypeBuildStateChange, "", false},
+		{`not json`, "", "", true},
+	}
+
+	for _, test := range tests {
+		ev, err := getSNSEvent(test.message)
+		if err == nil && test.expectedError {
+			t.Errorf("%s Failed: [%s] expected to throw an error, but no error", t.Name(), test.message)
+		} else if err != nil && !test.expectedError {
+			t.Errorf("%s Failed: [%s] error occurred [%s]", t.Name(), test.message, err.Error())
+		} else if err == nil && (ev.DetailType != test.expectedDetailType || ev.Detail.Pipeline != test.expectedPipeline) {
+			t.Errorf("%s Failed: [%s] expected [%s/%s] got [%s/%s]", t.Name(), test.message,
+				test.expectedDetailType, test.expectedPipeline, ev.DetailType, ev.Detail.Pipeline)
+		}
+	}
+}
+
+// TestGetSNSRecords will test getSNSRecords()
+func TestGetSNSRecords(t *testing.T) {
+	t.Parallel()
+
+	if records := getSNSRecords(newSNSEnvelope(t, "one", "two")); len(records) != 2 {
+		t.Fatal("expected two records", records)
+	}
+	if records := getSNSRecords(json.RawMessage(`{"detail-type":"CodePipeline Pipeline Execution State Change","detail":{}}`)); len(records) != 0 {
+		t.Fatal("expected no records for an EventBridge event", records)
+	}
+	if records := getSNSRecords(json.RawMessage(`{"Records":[{"eventSource":"aws:sqs","body":"{}"}]}`)); len(records) != 0 {
+		t.Fatal("expected no records for other sources", records)
+	}
+}
+
+// TestHandleRequest will test handleRequest() (SNS envelopes and EventBridge events)
+func TestHandleRequest(t *testing.T) {
+
+	t.Run("missing event detail", func(t *testing.T) {
+		if _, err := handleRequest(context.Background(), json.RawMessage(`{}`)); err == nil {
+			t.Fatal("error should have occurred")
+		}
+	})
+
+	t.Run("invalid payload", func(t *testing.T) {
+		if _, err := handleRequest(context.Background(), json.RawMessage(`[]`)); err == nil {
+			t.Fatal("error should have occurred")
+		}
+	})
+
+	t.Run("every sns record is processed", func(t *testing.T) {
+		_, err := handleRequest(context.Background(), newSNSEnvelope(t,
+			`{"detailType":"CodePipeline Pipeline Execution State Change","detail":{"execution-id":"12345"}}`,
+			`not json`,
+		))
+		if err == nil {
+			t.Fatal("error should have occurred")
+		} else if !strings.Contains(err.Error(), "sns message message-a: missing event param pipeline") ||
+			!strings.Contains(err.Error(), "sns message message-b:") {
+			t.Fatal("expected an error for each record", err.Error())
+		}
+	})
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+)
+
+// SQS defaults
+const (
+	snsNotificationType = "Notification"
+	sqsEventSource      = "aws:sqs"
+)
+
+// permanentError is a failure that will never succeed on retry (invalid events, unsupported sources, etc.)
+type permanentError struct {
+	err error
+}
+
+// Error will return the error message
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap will return the underlying error
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// permanent will mark the error as permanent (not retryable)
+func permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// isPermanentError will return true if retrying can never succeed
+//
+// Joined errors are permanent only if every error is permanent, anything unknown is retryable
+func isPermanentError(err error) bool {
+	switch e := err.(type) {
+	case *permanentError, *json.SyntaxError, *json.UnmarshalTypeError:
+		return true
+	case *githubError:
+		// Rate limits use 403 or 429, a 401 is retried with the reloaded configuration (rotated token)
+		return e.StatusCode >= http.StatusBadRequest && e.StatusCode < http.StatusInternalServerError &&
+			e.StatusCode != http.StatusUnauthorized && e.StatusCode != http.StatusForbidden &&
+			e.StatusCode != http.StatusRequestTimeout && e.StatusCode != http.StatusTooManyRequests
+	case awserr.RequestFailure:
+		return e.StatusCode() >= http.StatusBadRequest && e.StatusCode() < http.StatusInternalServerError &&
+			!request.IsErrorThrottle(e) && !request.IsErrorRetryable(e)
+	case interface{ Unwrap() []error }:
+		errs := e.Unwrap()
+		for _, joined := range errs {
+			if !isPermanentError(joined) {
+				return false
+			}
+		}
+		return len(errs) > 0
+	case interface{ Unwrap() error }:
+		return isPermanentError(e.Unwrap())
+	}
+	return false
+}
+
+// getSQSRecords will return the SQS records if the payload is an SQS batch (nil otherwise)
+func getSQSRecords(raw json.RawMessage) (records []events.SQSMessage) {
+	var sqsEvent events.SQSEvent
+	if err := json.Unmarshal(raw, &sqsEvent); err != nil {
+		return
+	}
+	for _, record := range sqsEvent.Records {
+		if record.EventSource == sqsEventSource {
+			records = append(records, record)
+		}
+	}
+	return
+}
+
+// processSQSRecords will process every SQS record and return the records to retry (partial batch failures)
+//
+// Permanent failures are sent to the dead-letter queue (SQS_DEAD_LETTER_QUEUE_URL) instead of being retried,
+// without a dead-letter queue they are retried until the redrive policy of the queue moves them
+func processSQSRecords(ctx context.Context, records []events.SQSMessage,
+	sqsSvc sqsiface.SQSAPI,
+) (response events.SQSEventResponse) {
+	for _, record := range records {
+		ev, err := getSQSEvent(record.Body)
+		if err == nil {
+			err = ProcessEvent(ev)
+		}
+		if err == nil {
+			continue
+		}
+
+		// Permanent failures go straight to the dead-letter queue
+		if isPermanentError(err) {
+			log.Printf("permanent failure for message: %s error: %s", record.MessageId, err.Error())
+			if len(getConfig().SQSDeadLetterQueueURL) > 0 {
+				if err = sendToDeadLetterQueue(ctx, sqsSvc, record, err); err == nil {
+					continue
+				}
+				log.Printf("unable to send message: %s to the dead-letter queue error: %s", record.MessageId, err.Error())
+			}
+		} else {
+			log.Printf("retryable failure for message: %s error: %s", record.MessageId, err.Error())
+		}
+		response.BatchItemFailures = append(response.BatchItemFailures, events.SQSBatchItemFailure{
+			ItemIdentifier: record.MessageId,
+		})
+	}
+	return
+}
+
+// getSQSEvent will parse the event from an SQS message body (EventBridge event or SNS notification)
+func getSQSEvent(body string) (ev event, err error) {
+
+	// SNS notifications delivered to SQS (without raw message delivery)
+	var envelope struct {
+		Message string `json:"Message"`
+		Type    string `json:"Type"`
+	}
+	if err = json.Unmarshal([]byte(body), &envelope); err != nil {
+		err = permanent(err)
+		return
+	}
+	if envelope.Type == snsNotificationType && len(envelope.Message) > 0 {
+		if ev, err = getSNSEvent(envelope.Message); err != nil {
+			err = permanent(err)
+		}
+		return
+	}
+
+	// EventBridge (CloudWatch) event
+	if err = json.Unmarshal([]byte(body), &ev); err != nil {
+		err = permanent(err)
+	}
+	return
+}
+
+// sendToDeadLetterQueue will send the message (with the failure reason) to the dead-letter queue
+func sendToDeadLetterQueue(ctx context.Context, sqsSvc sqsiface.SQSAPI, record events.SQSMessage, failure error) error {
+	_, err := sqsSvc.SendMessageWithContext(ctx, &sqs.SendMessageInput{
+		MessageAttributes: map[string]*sqs.MessageAttributeValue{
+			"ErrorMessage": {
+				DataType:    aws.String("String"),
+				StringValue: aws.String(failure.Error()),
+			},
+			"SourceMessageId": {
+				DataType:    aws.String("String"),
+				StringValue: aws.String(record.MessageId),
+			},
+		},
+		MessageBody: aws.String(record.Body),
+		QueueUrl:    aws.String(getConfig().SQSDeadLetterQueueURL),
+	})
+	return err
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+)
+
+// Mocking sqs client
+type mockSQSClient struct {
+	sqsiface.SQSAPI
+	messages []string
+}
+
+// SendMessageWithContext is a mock request for sqs
+func (m *mockSQSClient) SendMessageWithContext(_ aws.Context, input *sqs.SendMessageInput,
+	_ ...request.Option,
+) (*sqs.SendMessageOutput, error) {
+	if aws.StringValue(input.QueueUrl) == "missing-queue" {
+		return nil, fmt.Errorf("AWS.SimpleQueueService.NonExistentQueue")
+	}
+	m.messages = append(m.messages, aws.StringValue(input.MessageAttributes["SourceMessageId"].StringValue))
+	return &sqs.SendMessageOutput{}, nil
+}
+
+// TestIsPermanentError will test isPermanentError()
+func TestIsPermanentError(t *testing.T) {
+	t.Parallel()
+
+	var syntaxErr error
+	if err := json.Unmarshal([]byte("not json"), &struct{}{}); err != nil {
+		syntaxErr = err
+	}
+
+	var tests = []struct {
+		name              string
+		err               error
+		expectedPermanent bool
+	}{
+		{"permanent", permanent(errors.New("missing event param pipeline")), true},
+		{"wrapped permanent", fmt.Errorf("artifact SourceCode: %w", permanent(errors.New("invalid"))), true},
+		{"invalid json", syntaxErr, true},
+		{"github not found", &githubError{StatusCode: 404}, true},
+		{"github rate limit", &githubError{StatusCode: 429}, false},
+		{"github forbidden", &githubError{StatusCode: 403}, false},
+		{"github unauthorized", &githubError{StatusCode: 401}, false},
+		{"github outage", &githubError{StatusCode: 502}, false},
+		{"aws access denied", awserr.NewRequestFailure(awserr.New("AccessDeniedException", "denied", nil), 400, "id"), true},
+		{"aws throttle", awserr.NewRequestFailure(awserr.New("ThrottlingException", "slow down", nil), 400, "id"), false},
+		{"aws outage", awserr.NewRequestFailure(awserr.New("InternalFailure", "oops", nil), 500, "id"), false},
+		{"timeout", context.DeadlineExceeded, false},
+		{"joined permanent", errors.Join(permanent(errors.New("one")), &githubError{StatusCode: 422}), true},
+		{"joined mixed", errors.Join(permanent(errors.New("one")), &githubError{StatusCode: 503}), false},
+		{"unknown", errors.New("unknown"), false},
+	}
+
+	for _, test := range tests {
+		if isPermanentError(test.err) != test.expectedPermanent {
+			t.Errorf("%s Failed: [%s] expected permanent [%t]", t.Name(), test.name, test.expectedPermanent)
+		}
+	}
+}
+
+// TestGetSQSEvent will test getSQSEvent() and getSQSRecords()
+func TestGetSQSEvent(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		body             string
+		expectedPipeline string
+		expectedError    bool
+	}{
+		{`{"detail-type":"CodePipeline Pipeline Execution State Change","detail":{"pipeline":"my-pipeline"}}`, "my-pipeline", false},
+		{`{"Type":"Notification","Message":"{\"detailType\":\"CodePipeline Pipeline Execution State Change\",\"detail\":{\"pipeline\":\"my-pipeline\"}}"}`, "my-pipeline", false},
+		{`{"Type":"Notification","Message":"not json"}`, "", true},
+		{`not json`, "", true},
+	}
+
+	for _, test := range tests {
+		ev, err := getSQSEvent(test.body)
+		if err == nil && test.expectedError {
+			t.Errorf("%s Failed: [%s] expected to throw an error, but no error", t.Name(), test.body)
+		} else if err != nil && (!test.expectedError || !isPermanentError(err)) {
+			t.Errorf("%s Failed: [%s] unexpected error [%s]", t.Name(), test.body, err.Error())
+		} else if err == nil && ev.Detail.Pipeline != test.expectedPipeline {
+			t.Errorf("%s Failed: [%s] expected pipeline [%s] got [%s]", t.Name(), test.body, test.expectedPipeline, ev.Detail.Pipeline)
+		}
+	}
+
+	raw := json.RawMessage(`{"Records":[{"messageId":"1","eventSource":"aws:sqs","body":"{}"},{"messageId":"2","eventSource":"aws:sqs","body":"{}"}]}`)
+	if records := getSQSRecords(raw); len(records) != 2 {
+		t.Fatal("expected two records", records)
+	}
+	if records := getSQSRecords(newSNSEnvelope(t, "{}")); len(records) != 0 {
+		t.Fatal("expected no records for an SNS event", records)
+	}
+}
+
+// TestProcessSQSRecords will test processSQSRecords() (permanent failures)
+func TestProcessSQSRecords(t *testing.T) {
+
+	records := []events.SQSMessage{
+		{MessageId: "invalid-json", Body: "not json"},
+		{MessageId: "missing-detail", Body: "{}"},
+	}
+
+	t.Run("dead-letter queue", func(t *testing.T) {
+		setConfig(t, func(c *loadedConfiguration) {
+			c.SQSDeadLetterQueueURL = "https://sqs.us-east-1.amazonaws.com/123456789012/dead-letters"
+		})
+		mockSQS := &mockSQSClient{}
+		response := processSQSRecords(context.Background(), records, mockSQS)
+		if len(response.BatchItemFailures) != 0 {
+			t.Fatal("expected no batch item failures", response.BatchItemFailures)
+		} else if len(mockSQS.messages) != 2 {
+			t.Fatal("expected both messages in the dead-letter queue", mockSQS.messages)
+		}
+	})
+
+	t.Run("dead-letter queue failure", func(t *testing.T) {
+		setConfig(t, func(c *loadedConfiguration) {
+			c.SQSDeadLetterQueueURL = "missing-queue"
+		})
+		response := processSQSRecords(context.Background(), records, &mockSQSClient{})
+		if len(response.BatchItemFailures) != 2 {
+			t.Fatal("expected both messages to be retried", response.BatchItemFailures)
+		}
+	})
+
+	t.Run("no dead-letter queue", func(t *testing.T) {
+		mockSQS := &mockSQSClient{}
+		response := processSQSRecords(context.Background(), records, mockSQS)
+		if len(response.BatchItemFailures) != 2 || response.BatchItemFailures[0].ItemIdentifier != "invalid-json" {
+			t.Fatal("expected both messages as batch item failures", response.BatchItemFailures)
+		} else if len(mockSQS.messages) != 0 {
+			t.Fatal("expected no messages in the dead-letter queue", mockSQS.messages)
+		}
+	})
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+)
+
+// ssmCredentials is the GitHub token read from an SSM Parameter Store SecureString at runtime (GITHUB_TOKEN_PARAMETER)
+type ssmCredentials struct {
+	cache         *cachedToken // Defaults to githubTokenCache
+	parameterName string
+	ssmSvc        ssmiface.SSMAPI
+	ttl           time.Duration // GITHUB_TOKEN_TTL (zero is the default)
+}
+
+// Name will return the provider name
+func (c *ssmCredentials) Name() string {
+	return credentialProviderSSM
+}
+
+// Token will return the cached token, or read the parameter
+func (c *ssmCredentials) Token(refresh bool) (string, error) {
+	return getTokenCache(c.cache).get(credentialProviderSSM+":"+c.parameterName, c.ttl, refresh, func() (string, error) {
+		if c.ssmSvc == nil {
+			c.ssmSvc = ssm.New(awsSession)
+		}
+		return getParameterValue(c.ssmSvc, c.parameterName)
+	})
+}
+
+// getParameterValue will read the (decrypted) value of a SecureString parameter
+// In order for this method to work, the function needs ssm:GetParameter and kms:Decrypt on the parameter key
+func getParameterValue(ssmSvc ssmiface.SSMAPI, name string) (string, error) {
+	output, err := ssmSvc.GetParameter(&ssm.GetParameterInput{
+		Name:           aws.String(name),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		return "", err
+	} else if output == nil || output.Parameter == nil {
+		return "", errors.New("missing parameter: " + name)
+	}
+
+	value := strings.TrimSpace(aws.StringValue(output.Parameter.Value))
+	if len(value) == 0 {
+		return "", errors.New("missing token in parameter: " + name)
+	}
+	return value, nil
+}
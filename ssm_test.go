@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+)
+
+// Mocking ssm client
+type mockSSMClient struct {
+	ssmiface.SSMAPI
+	calls int
+}
+
+// GetParameter is a mock request for ssm
+func (m *mockSSMClient) GetParameter(input *ssm.GetParameterInput) (*ssm.GetParameterOutput, error) {
+	if !aws.BoolValue(input.WithDecryption) {
+		return nil, errors.New("expected the parameter to be decrypted")
+	}
+	m.calls++
+	switch aws.StringValue(input.Name) {
+	case "/codepipeline-to-github/production/github_token":
+		return &ssm.GetParameterOutput{Parameter: &ssm.Parameter{
+			Name:  input.Name,
+			Type:  aws.String(ssm.ParameterTypeSecureString),
+			Value: aws.String("parameter-token\n"),
+		}}, nil
+	case "/empty":
+		return &ssm.GetParameterOutput{Parameter: &ssm.Parameter{Name: input.Name, Value: aws.String("")}}, nil
+	case "/nil":
+		return &ssm.GetParameterOutput{}, nil
+	}
+	return nil, errors.New("ParameterNotFound: " + aws.StringValue(input.Name))
+}
+
+// TestGetParameterValue will test getParameterValue()
+func TestGetParameterValue(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		name          string
+		expectedValue string
+		expectedError bool
+	}{
+		{"/codepipeline-to-github/production/github_token", "parameter-token", false},
+		{"/empty", "", true},
+		{"/nil", "", true},
+		{"/missing", "", true},
+	}
+
+	for _, test := range tests {
+		value, err := getParameterValue(&mockSSMClient{}, test.name)
+		if err == nil && test.expectedError {
+			t.Errorf("%s Failed: [%s] expected to throw an error, but no error", t.Name(), test.name)
+		} else if err != nil && !test.expectedError {
+			t.Errorf("%s Failed: [%s] error occurred [%s]", t.Name(), test.name, err.Error())
+		} else if value != test.expectedValue {
+			t.Errorf("%s Failed: [%s] expected [%s] got [%s]", t.Name(), test.name, test.expectedValue, value)
+		}
+	}
+}
+
+// TestSSMCredentials will test the ssm provider (cached)
+func TestSSMCredentials(t *testing.T) {
+
+	previous := githubTokenCache
+	githubTokenCache = &cachedToken{}
+	defer func() {
+		githubTokenCache = previous
+	}()
+
+	mockSSM := &mockSSMClient{}
+	provider := &ssmCredentials{parameterName: "/codepipeline-to-github/production/github_token", ssmSvc: mockSSM}
+	if provider.Name() != credentialProviderSSM {
+		t.Fatal("provider name was not as expected", provider.Name())
+	}
+
+	for i := 0; i < 2; i++ {
+		if token, err := provider.Token(false); err != nil || token != "parameter-token" {
+			t.Fatal("expected the token of the parameter", token, err)
+		}
+	}
+	if mockSSM.calls != 1 {
+		t.Fatal("expected the token to be cached", mockSSM.calls)
+	} else if _, err := provider.Token(true); err != nil || mockSSM.calls != 2 {
+		t.Fatal("expected the parameter to be read again on a refresh", err, mockSSM.calls)
+	}
+
+	// Expired
+	githubTokenCache.expires = time.Now().Add(-time.Second)
+	if _, err := provider.Token(false); err != nil || mockSSM.calls != 3 {
+		t.Fatal("expected the parameter to be read again after the ttl", err, mockSSM.calls)
+	}
+}
@@ -6,25 +6,34 @@ More information: https://github.com/mrz1836/codepipeline-to-github
 package main
 
 import (
-	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
 	"net/url"
+	"os"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-lambda-go/lambdacontext"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/codecommit"
 	"github.com/aws/aws-sdk-go/service/codepipeline"
 	"github.com/aws/aws-sdk-go/service/codepipeline/codepipelineiface"
 	"github.com/aws/aws-sdk-go/service/kms"
 	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sts"
 	"github.com/kelseyhightower/envconfig"
 )
 
@@ -35,17 +44,53 @@ const (
 	// stageProduction    = "production"
 )
 
-// event is what is emitted by CloudWatch
+// event is what is emitted by CloudWatch (EventBridge envelope)
 type event struct {
-	Detail    *detail  `json:"detail"`
-	Resources []string `json:"resources"`
+	Account    string          `json:"account,omitempty"`
+	Detail     *detail         `json:"detail"`
+	DetailType string          `json:"detail-type"`
+	ID         string          `json:"id,omitempty"`
+	Region     string          `json:"region,omitempty"`
+	RawDetail  json.RawMessage `json:"-"` // Decoded by the handlers with their own schema (IE: status events)
+	Resources  []string        `json:"resources"`
+	Source     string          `json:"source,omitempty"`
+	Time       time.Time       `json:"time,omitempty"`
+	Version    string          `json:"version,omitempty"`
+}
+
+// UnmarshalJSON will decode the event and keep the raw detail
+func (e *event) UnmarshalJSON(data []byte) error {
+	type rawEvent event
+	if err := json.Unmarshal(data, (*rawEvent)(e)); err != nil {
+		return err
+	}
+	var raw struct {
+		Detail json.RawMessage `json:"detail"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	e.RawDetail = raw.Detail
+	return nil
 }
 
 // detail is the custom event information
 type detail struct {
-	ExecutionID string `json:"execution-id"`
-	State       string `json:"state"`
-	Pipeline    string `json:"pipeline"`
+	Action                string            `json:"action,omitempty"`
+	AdditionalInformation *buildInformation `json:"additional-information,omitempty"`
+	Application           string            `json:"application,omitempty"`
+	BuildID               string            `json:"build-id,omitempty"`
+	BuildStatus           string            `json:"build-status,omitempty"`
+	DeploymentGroup       string            `json:"deploymentGroup,omitempty"`
+	DeploymentID          string            `json:"deploymentId,omitempty"`
+	ExecutionID           string            `json:"execution-id"`
+	ExecutionTrigger      *executionTrigger `json:"execution-trigger,omitempty"`
+	Pipeline              string            `json:"pipeline"`
+	ProjectName           string            `json:"project-name,omitempty"`
+	Stage                 string            `json:"stage,omitempty"`
+	State                 string            `json:"state"`
+	Type                  *actionType       `json:"type,omitempty"`
+	Version               json.Number       `json:"version,omitempty"` // Number (pipeline) or string (build)
 }
 
 // payload is the data payload to send GitHub
@@ -56,18 +101,107 @@ type payload struct {
 	TargetURL   string `json:"target_url"`
 }
 
+// sourceRevision is a commit (and repository url) from a source artifact
+type sourceRevision struct {
+	ArtifactName string
+	Commit       string
+	PullRequest  int    // Set from V2 pull request triggers
+	Repository   string // Resolved from metadata (S3 sources) instead of the revision url
+	RevisionURL  *url.URL
+}
+
 // configuration is for the application's configuration settings
 type configuration struct {
-	AWSRegion         string `required:"true" split_words:"true" envconfig:"AWS_REGION"`
-	GithubAccessToken string `required:"true" split_words:"true" envconfig:"GITHUB_ACCESS_TOKEN"`
-	Stage             string `required:"true" split_words:"true" envconfig:"APPLICATION_STAGE_NAME"`
+	AllSourceArtifacts       bool              `default:"true" split_words:"true" envconfig:"ALL_SOURCE_ARTIFACTS"`
+	ApprovalPipelines        []string          `default:"all" split_words:"true" envconfig:"APPROVAL_PIPELINES"`
+	AWSRegion                string            `required:"true" split_words:"true" envconfig:"AWS_REGION"`
+	CodeCommitApprovalState  bool              `split_words:"true" envconfig:"CODECOMMIT_APPROVAL_STATE"`
+	ConfigCacheTTL           time.Duration     `split_words:"true" envconfig:"CONFIG_CACHE_TTL"` // Zero caches per container
+	CrossAccountRoleName     string            `split_words:"true" envconfig:"CROSS_ACCOUNT_ROLE_NAME"`
+	DryRun                   bool              `split_words:"true" envconfig:"DRY_RUN"` // Log the requests instead of sending them
+	EventKinds               []string          `default:"pipeline,approval,build,deployment,schedule,status" split_words:"true" envconfig:"EVENT_KINDS"`
+	GithubAccessToken        string            `split_words:"true" envconfig:"GITHUB_ACCESS_TOKEN"` // Required by the env and kms providers
+	GithubAPIURL             string            `default:"https://api.github.com" split_words:"true" envconfig:"GITHUB_API_URL"`
+	GithubApprovers          []string          `split_words:"true" envconfig:"GITHUB_APPROVERS"`
+	GithubOwnerTokens        map[string]string `split_words:"true" envconfig:"GITHUB_OWNER_TOKENS"` // owner:encrypted-token
+	GithubTokenParameter     string            `split_words:"true" envconfig:"GITHUB_TOKEN_PARAMETER"`
+	GithubTokenProvider      string            `split_words:"true" envconfig:"GITHUB_TOKEN_PROVIDER"`
+	GithubTokenSecretARN     string            `split_words:"true" envconfig:"GITHUB_TOKEN_SECRET_ARN"`
+	GithubTokenSecretKey     string            `split_words:"true" envconfig:"GITHUB_TOKEN_SECRET_KEY"`
+	GithubTokenTTL           time.Duration     `default:"5m" split_words:"true" envconfig:"GITHUB_TOKEN_TTL"`
+	GithubWebhookSecret      string            `split_words:"true" envconfig:"GITHUB_WEBHOOK_SECRET"`
+	HTTPAuth                 string            `default:"secret" split_words:"true" envconfig:"HTTP_AUTH"`
+	HTTPSharedSecret         string            `split_words:"true" envconfig:"HTTP_SHARED_SECRET"`
+	KMSEncryptionContext     map[string]string `split_words:"true" envconfig:"KMS_ENCRYPTION_CONTEXT"`   // Default: LambdaFunctionName
+	LambdaFunctionName       string            `split_words:"true" envconfig:"AWS_LAMBDA_FUNCTION_NAME"` // Set by Lambda
+	Publishers               []string          `default:"github,codecommit" split_words:"true" envconfig:"PUBLISHERS"`
+	PublisherTimeout         time.Duration     `default:"4s" split_words:"true" envconfig:"PUBLISHER_TIMEOUT"`
+	ReconcileMaxExecutions   int               `default:"20" split_words:"true" envconfig:"RECONCILE_MAX_EXECUTIONS"`
+	ReconcilePipelines       []string          `default:"all" split_words:"true" envconfig:"RECONCILE_PIPELINES"`
+	ReconcileTimeout         time.Duration     `default:"4m30s" split_words:"true" envconfig:"RECONCILE_TIMEOUT"` // Below the function timeout
+	ReconcileWindow          time.Duration     `default:"24h" split_words:"true" envconfig:"RECONCILE_WINDOW"`
+	RoutingConfig            string            `split_words:"true" envconfig:"ROUTING_CONFIG"` // File or s3://bucket/key
+	SlackWebhookURL          string            `split_words:"true" envconfig:"SLACK_WEBHOOK_URL"`
+	SourceArtifactAutoDetect bool              `split_words:"true" envconfig:"SOURCE_ARTIFACT_AUTO_DETECT"`
+	SourceArtifactMap        map[string]string `split_words:"true" envconfig:"SOURCE_ARTIFACT_MAP"`
+	SourceArtifactNames      []string          `default:"SourceCode" split_words:"true" envconfig:"SOURCE_ARTIFACT_NAMES"`
+	SQSDeadLetterQueueURL    string            `split_words:"true" envconfig:"SQS_DEAD_LETTER_QUEUE_URL"`
+	S3MetadataCommitKey      string            `default:"commit" split_words:"true" envconfig:"S3_METADATA_COMMIT_KEY"`
+	S3MetadataRepositoryKey  string            `default:"repository" split_words:"true" envconfig:"S3_METADATA_REPOSITORY_KEY"`
+	Stage                    string            `split_words:"true" envconfig:"APPLICATION_STAGE_NAME"` // Required (checked after the token provider)
+	WebhookURLs              []string          `split_words:"true" envconfig:"WEBHOOK_URLS"`
 }
 
-// Local application variables
-var (
-	awsSession *session.Session
-	config     configuration
-)
+// Local application variables (the configuration is loaded by getConfiguration, see getConfig)
+var awsSession *session.Session
+
+// handleRequest is the Lambda entry point (EventBridge events, SNS notifications, SQS batches or http requests)
+func handleRequest(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+	setFunctionAccount(ctx)
+
+	// Function URL or API Gateway requests (manual status refresh)
+	if request := getFunctionURLRequest(raw); request != nil {
+		return handleFunctionURLRequest(ctx, request)
+	}
+
+	// SQS batches (reports partial batch failures)
+	if records := getSQSRecords(raw); len(records) > 0 {
+		if err := getConfiguration(); err != nil {
+			return nil, err
+		}
+		return processSQSRecords(ctx, records, sqs.New(awsSession)), nil
+	}
+
+	// SNS notifications (CodePipeline notification rules)
+	if records := getSNSRecords(raw); len(records) > 0 {
+		return nil, processSNSRecords(records)
+	}
+
+	// EventBridge (CloudWatch) event, or a direct invocation (returns the requests on a dry-run)
+	var ev event
+	if err := json.Unmarshal(raw, &ev); err != nil {
+		return nil, err
+	}
+
+	// Verify the credentials and permissions (returns the report)
+	if ev.DetailType == detailTypeVerify {
+		var pipelineName string
+		if ev.Detail != nil {
+			pipelineName = ev.Detail.Pipeline
+		}
+		report := verify(ctx, pipelineName, codepipeline.New(awsSession))
+		logVerifyReport(report)
+		return report, nil
+	}
+
+	requests, err := runDryRun(func() error {
+		return ProcessEvent(ev)
+	})
+	if getConfig().DryRun {
+		return &httpResponse{Requests: requests, Status: statusDryRun}, err
+	}
+	return nil, err
+}
 
 // ProcessEvent is triggered by a CloudWatch event rule
 func ProcessEvent(ev event) error {
@@ -76,99 +210,246 @@ func ProcessEvent(ev event) error {
 	if ev.Detail != nil {
 		log.Printf("Incoming Event Details: %+v\n", ev.Detail)
 	} else {
-		return errors.New("missing param event.detail")
+		return permanent(errors.New("missing param event.detail"))
 	}
-	if len(ev.Detail.ExecutionID) == 0 {
-		return errors.New("missing event param execution-id")
-	}
-	if len(ev.Detail.Pipeline) == 0 {
-		return errors.New("missing event param pipeline")
+
+	// Find the handler for the kind of event (detail-type)
+	handler, ok := getEventHandler(ev)
+	if !ok {
+		log.Printf("ignoring unsupported event: %s", ev.DetailType)
+		return nil
 	}
 
-	// Create a new KMS session
-	kmsSvc := kms.New(awsSession)
+	// Validate the event before loading the configuration
+	if err := handler.validate(ev); errors.Is(err, errSkipEvent) {
+		return nil
+	} else if err != nil {
+		return err
+	}
 
 	// Load the configuration
-	if err := loadConfiguration(kmsSvc); err != nil {
+	if err := getConfiguration(); err != nil {
 		return err
 	}
 
-	// Start a new CodePipeline service
-	pipeline := codepipeline.New(awsSession)
+	// Ignore the kinds of events that are not enabled (EVENT_KINDS)
+	if !isEventKindEnabled(handler.kind, ev.Detail.Pipeline) {
+		log.Printf("ignoring %s event: %s (not in EVENT_KINDS)", handler.kind, ev.DetailType)
+		return nil
+	}
+
+	return handler.process(ev)
+}
+
+// validateExecutionEvent will check the required parameters of a pipeline execution event
+func validateExecutionEvent(ev event) error {
+	if len(ev.Detail.ExecutionID) == 0 {
+		return permanent(errors.New("missing event param execution-id"))
+	}
+	if len(ev.Detail.Pipeline) == 0 {
+		return permanent(errors.New("missing event param pipeline"))
+	}
+	return nil
+}
+
+// processPipelineEvent will post the status of the pipeline execution for every source revision
+func processPipelineEvent(ev event) error {
+	return processExecutionEvent(ev, nil)
+}
+
+// processExecutionEvent will publish a status update for every source revision of the pipeline execution
+// The status is based on the execution status, customize can change the update per event kind (stage, action)
+func processExecutionEvent(ev event, customize func(update *statusUpdate)) error {
 
-	// Get the commit info from the pipeline execution
-	commit, githubStatus, revisionURL, err := getCommit(ev.Detail.Pipeline, ev.Detail.ExecutionID, pipeline)
+	// Start a new CodePipeline service (event region and account)
+	eventSession := getEventSession(ev)
+	pipeline := codepipeline.New(eventSession)
+
+	// Get the source revisions from the pipeline execution
+	revisions, githubStatus, err := getRevisions(ev.Detail.Pipeline, ev.Detail.ExecutionID, pipeline, s3.New(eventSession))
 	if err != nil {
 		return err
-	} else if revisionURL == nil {
-		return errors.New("unable to find the revision url, possibly missing source artifacts")
+	} else if len(revisions) == 0 {
+		return permanent(errors.New("unable to find the revision url, possibly missing source artifacts"))
 	}
 
-	// Break apart the components
-	parts := strings.Split(revisionURL.Path, "/")
-	owner := parts[1]
-	repo := parts[2]
-
 	// Set up the links
 	deepLink := fmt.Sprintf(
 		"https://%s.console.aws.amazon.com/codesuite/codepipeline/pipelines/%s/executions/%s",
-		config.AWSRegion, ev.Detail.Pipeline, ev.Detail.ExecutionID)
-	githubURL := fmt.Sprintf("https://api.github.com/repos/%s/%s/statuses/%s", owner, repo, commit)
-
-	// Create the GitHub payload
-	var b bytes.Buffer
-	if err = json.NewEncoder(&b).Encode(payload{
-		Context:   "continuous-integration/codepipeline",
-		State:     githubStatus,
-		TargetURL: deepLink,
-	}); err != nil {
+		getRegion(ev), ev.Detail.Pipeline, ev.Detail.ExecutionID)
+
+	// Get the publishers (GitHub, CodeCommit, chat, webhooks) and the credential of the pipeline
+	publishers, err := getPublishers(codecommit.New(eventSession), ev.Detail.Pipeline)
+	if err != nil {
 		return err
 	}
+	ctx := withCredentials(context.Background(), getRouteCredentials(ev.Detail.Pipeline))
+
+	// Publish the status for each source revision concurrently (collect the errors per artifact)
+	// Publishing takes at most one PUBLISHER_TIMEOUT, whatever the number of source artifacts
+	errs := make([]error, len(revisions))
+	var wg sync.WaitGroup
+	for i, revision := range revisions {
+		wg.Add(1)
+		go func(i int, revision *sourceRevision) {
+			defer wg.Done()
+			update, updateErr := newStatusUpdate(
+				revision, ev.Detail.ExecutionTrigger, githubStatus, ev.Detail.Pipeline, ev.Detail.ExecutionID, deepLink,
+			)
+			if updateErr == nil {
+				if customize != nil {
+					customize(update)
+					update.State = getRouteState(ev.Detail.Pipeline, ev.Detail.State, update.State)
+				}
+				applyRouteContext(update, getExecutionKind(ev), ev.Detail.Stage, ev.Detail.Action)
+				updateErr = publishStatus(ctx, publishers, update)
+			}
+			if updateErr != nil {
+				errs[i] = fmt.Errorf("artifact %s: %w", revision.ArtifactName, updateErr)
+			}
+		}(i, revision)
+	}
+	wg.Wait()
 
-	// Create the request
-	var req *http.Request
-	if req, err = http.NewRequestWithContext(context.Background(), http.MethodPost, githubURL, &b); err != nil {
-		return err
+	return errors.Join(errs...)
+}
+
+// newStatusUpdate will create the status update for a source revision
+func newStatusUpdate(revision *sourceRevision, trigger *executionTrigger,
+	githubStatus, pipelineName, executionID, deepLink string,
+) (*statusUpdate, error) {
+	update := &statusUpdate{
+		ArtifactName: revision.ArtifactName,
+		Commit:       revision.Commit,
+		Context:      githubContext,
+		Description:  getStatusDescription(githubStatus, revision, trigger),
+		ExecutionID:  executionID,
+		Pipeline:     pipelineName,
+		Provider:     providerGitHub,
+		PullRequest:  revision.PullRequest,
+		State:        githubStatus,
+		TargetURL:    deepLink,
 	}
 
-	// Set the headers
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Authorization", "token "+config.GithubAccessToken)
-	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	// CodeCommit sources get feedback on the matching pull requests
+	if len(revision.Repository) == 0 && isCodeCommitURL(revision.RevisionURL) {
+		update.Provider = providerCodeCommit
+		update.Repository = getCodeCommitRepository(revision.RevisionURL)
+		return update, nil
+	}
 
-	// Fire the request
-	var response *http.Response
-	if response, err = http.DefaultClient.Do(req); err != nil {
-		return err
+	// Break apart the components
+	var err error
+	if len(revision.Repository) > 0 {
+		update.Owner, update.Repository, err = parseRepository(revision.Repository)
+	} else {
+		update.Owner, update.Repository, err = getGitHubRepository(revision.RevisionURL)
 	}
-	defer func() {
-		_ = response.Body.Close()
-	}()
+	return update, err
+}
 
-	// Check for success
-	if response.StatusCode != http.StatusCreated {
-		resBody, _ := io.ReadAll(response.Body)
-		return fmt.Errorf("unexpected response from GitHub, code: %d body: %s", response.StatusCode, string(resBody))
+// getRegion will return the region of the event (defaults to AWS_REGION)
+func getRegion(ev event) string {
+	if len(ev.Region) > 0 {
+		return ev.Region
 	}
+	return getConfig().AWSRegion
+}
 
-	return nil
+// setFunctionAccount will keep the account of the function from the Lambda context (the invoked function ARN)
+func setFunctionAccount(ctx context.Context) {
+	lc, ok := lambdacontext.FromContext(ctx)
+	if !ok {
+		return
+	}
+	functionARN, err := arn.Parse(lc.InvokedFunctionArn)
+	if err != nil || len(functionARN.AccountID) == 0 {
+		return
+	}
+	functionAccount.lock.Lock()
+	defer functionAccount.lock.Unlock()
+	functionAccount.account = functionARN.AccountID
+}
+
+// getFunctionAccount will return the account of the function (cached), from STS if not set by the Lambda context
+// Returns empty if the account could not be found
+func getFunctionAccount() string {
+	functionAccount.lock.Lock()
+	defer functionAccount.lock.Unlock()
+	if len(functionAccount.account) > 0 {
+		return functionAccount.account
+	}
+
+	if functionAccount.stsSvc == nil {
+		functionAccount.stsSvc = sts.New(awsSession)
+	}
+	identity, err := functionAccount.stsSvc.GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	if err != nil {
+		log.Printf("unable to get the account of the function: %s", err.Error())
+		return ""
+	}
+	functionAccount.account = aws.StringValue(identity.Account)
+	return functionAccount.account
+}
+
+// getEventSession will return the AWS session for the event's region and account (cached, clients and credentials are reused)
+//
+// Events from other accounts assume the CROSS_ACCOUNT_ROLE_NAME role in that account (if set),
+// events from the account of the function use its own credentials
+func getEventSession(ev event) *session.Session {
+	region := getRegion(ev)
+	var roleARN string
+	if roleName := getConfig().CrossAccountRoleName; len(roleName) > 0 && len(ev.Account) > 0 &&
+		ev.Account != getFunctionAccount() {
+		roleARN = fmt.Sprintf("arn:aws:iam::%s:role/%s", ev.Account, roleName)
+	}
+
+	eventSessions.lock.Lock()
+	defer eventSessions.lock.Unlock()
+	key := region + "|" + roleARN
+	if eventSession, ok := eventSessions.sessions[key]; ok {
+		return eventSession
+	}
+
+	eventSession := awsSession.Copy(&aws.Config{Region: aws.String(region)})
+	if len(roleARN) > 0 {
+		eventSession.Config.Credentials = stscreds.NewCredentials(awsSession, roleARN)
+	}
+	eventSessions.sessions[key] = eventSession
+	return eventSession
 }
 
 // loadConfiguration will decrypt any encrypted variables
+// The configuration is built into a new value and replaces the current one when complete (see getConfig)
 func loadConfiguration(kmsSvc kmsiface.KMSAPI) (err error) {
 
 	// Get configuration set using environment variables
-	if err = envconfig.Process("", &config); err != nil {
+	loaded := &loadedConfiguration{}
+	if err = envconfig.Process("", &loaded.configuration); err != nil {
 		return
 	}
 
-	// Skip KMS on testing stage
-	if config.Stage == stageTesting {
+	// Get the provider of the token (env, KMS, SSM or Secrets Manager) and check the required keys
+	if loaded.credentials, err = getCredentialProvider(&loaded.configuration, kmsSvc); err != nil {
 		return
+	} else if len(loaded.Stage) == 0 {
+		return errors.New("required key APPLICATION_STAGE_NAME missing value")
 	}
 
-	// Update the Token with the decoded value or fail
-	config.GithubAccessToken, err = decryptString(kmsSvc, config.GithubAccessToken)
+	// Get the token (default) and the tokens of the owners (decrypted on first use)
+	if loaded.GithubAccessToken, err = loaded.credentials.Token(false); err != nil {
+		return
+	} else if loaded.owners, err = getOwnerCredentials(&loaded.configuration, kmsSvc, loaded.credentials); err != nil {
+		return
+	}
+
+	// Load the routes of the pipelines (validated, credentials are decrypted on first use)
+	if loaded.routes, err = loadRoutingConfig(&loaded.configuration, loaded.RoutingConfig, kmsSvc, nil); err != nil {
+		return
+	}
+
+	// Replace the current configuration
+	currentConfiguration.Store(loaded)
 	return
 }
 
@@ -182,12 +463,12 @@ func getCommit(pipelineName, executionID string, pipeline codepipelineiface.Code
 	}
 
 	// Find the source artifacts
-	sourceArtifact := getArtifact(executionOutput)
+	sourceArtifact := getArtifact(executionOutput, pipeline)
 
 	// No artifact to work with (this occurs if a "Release Change" event is fired)
 	if sourceArtifact == nil {
 		log.Printf("no %s found in execution: %s for pipeline: %s",
-			sourceArtifactName, *executionOutput.PipelineExecution.PipelineExecutionId, *executionOutput.PipelineExecution.PipelineName)
+			strings.Join(getSourceArtifactNames(pipelineName), ", "), *executionOutput.PipelineExecution.PipelineExecutionId, *executionOutput.PipelineExecution.PipelineName)
 		return
 	}
 
@@ -198,11 +479,79 @@ func getCommit(pipelineName, executionID string, pipeline codepipelineiface.Code
 	if revisionURL, err = url.Parse(aws.StringValue(sourceArtifact.RevisionUrl)); err != nil {
 		return
 	} else if revisionURL == nil {
-		err = fmt.Errorf("missing %s: %s", sourceArtifactName, "RevisionUrl")
+		err = fmt.Errorf("missing %s: %s", aws.StringValue(sourceArtifact.Name), "RevisionUrl")
+	}
+
+	// Set the status based on the pipeline status
+	status = getExecutionStatus(executionOutput)
+
+	return
+}
+
+// getRevisions will get the source revisions (commit and revision url) from an execution
+//
+// By default, every source artifact is returned (see getArtifacts), if disabled, only
+// the source artifact is used (see getArtifact)
+func getRevisions(pipelineName, executionID string,
+	pipeline codepipelineiface.CodePipelineAPI, s3Svc s3iface.S3API,
+) (revisions []*sourceRevision, status string, err error) {
+
+	// Get the execution details
+	var executionOutput *codepipeline.GetPipelineExecutionOutput
+	if executionOutput, err = getExecutionOutput(pipelineName, executionID, pipeline); err != nil {
+		return
+	}
+
+	// Only the source artifact, or every source artifact (SCM revision url or S3 source)
+	var artifacts []*codepipeline.ArtifactRevision
+	if getConfig().AllSourceArtifacts {
+		artifacts = getArtifacts(executionOutput, pipeline)
+	} else if sourceArtifact := getArtifact(executionOutput, pipeline); sourceArtifact != nil {
+		artifacts = append(artifacts, sourceArtifact)
+	}
+
+	// Parse the revision URLs
+	for _, artifact := range artifacts {
+		var revisionURL *url.URL
+		if revisionURL, err = url.Parse(aws.StringValue(artifact.RevisionUrl)); err != nil {
+			return
+		}
+
+		// S3 sources (no SCM revision url) carry the commit in their metadata
+		if !isGitHubURL(revisionURL) && !isCodeCommitURL(revisionURL) {
+			var s3Revision *sourceRevision
+			if s3Revision, err = getS3Revision(pipelineName, artifact, pipeline, s3Svc); err != nil {
+				return
+			} else if s3Revision != nil {
+				revisions = append(revisions, s3Revision)
+				continue
+			}
+		}
+
+		revisions = append(revisions, &sourceRevision{
+			ArtifactName: aws.StringValue(artifact.Name),
+			Commit:       aws.StringValue(artifact.RevisionId),
+			RevisionURL:  revisionURL,
+		})
+	}
+
+	// Use the V2 trigger details for the head commit and pull request (not required)
+	if triggerErr := applyExecutionTrigger(executionOutput, pipeline, revisions); triggerErr != nil {
+		log.Printf("unable to get the trigger details for execution: %s error: %s", executionID, triggerErr.Error())
 	}
 
 	// Set the status based on the pipeline status
-	switch aws.StringValue(executionOutput.PipelineExecution.Status) {
+	status = getExecutionStatus(executionOutput)
+
+	return
+}
+
+// getExecutionStatus will return the GitHub status based on the pipeline execution status
+// The states of the pipeline route take precedence (IE: Stopped is an error)
+func getExecutionStatus(executionOutput *codepipeline.GetPipelineExecutionOutput) string {
+	executionStatus := aws.StringValue(executionOutput.PipelineExecution.Status)
+	var status string
+	switch executionStatus {
 	case "InProgress":
 		status = "pending"
 	case "Succeeded":
@@ -210,13 +559,14 @@ func getCommit(pipelineName, executionID string, pipeline codepipelineiface.Code
 	default:
 		status = "failure"
 	}
-
-	return
+	return getRouteState(aws.StringValue(executionOutput.PipelineExecution.PipelineName), executionStatus, status)
 }
 
-// decryptString uses AWS Key Management Service (AWS KMS) to decrypt environment variables.
+// decryptConfigString uses AWS Key Management Service (AWS KMS) to decrypt environment variables.
 // In order for this method to work, the function needs access to the kms:Decrypt capability.
-func decryptString(kmsSvc kmsiface.KMSAPI, encryptedText string) (string, error) {
+// The encryption context of the configuration (getEncryptionContext) binds the value to this function
+// (Lambda console encryption helpers)
+func decryptConfigString(cfg *configuration, kmsSvc kmsiface.KMSAPI, encryptedText string) (string, error) {
 
 	// Decode the encryptedText
 	sDec, err := base64.StdEncoding.DecodeString(encryptedText)
@@ -224,18 +574,45 @@ func decryptString(kmsSvc kmsiface.KMSAPI, encryptedText string) (string, error)
 		return "", err
 	}
 
-	// Decrypt the decoded text
+	// Decrypt the decoded text (with the encryption context)
+	encryptionContext, isDefault := getEncryptionContext(cfg)
 	var out *kms.DecryptOutput
 	if out, err = kmsSvc.Decrypt(&kms.DecryptInput{
-		CiphertextBlob: sDec,
+		CiphertextBlob:    sDec,
+		EncryptionContext: encryptionContext,
 	}); err != nil {
-		return "", err
+
+		// Values encrypted without a context (make encrypt) still work with the default context
+		// A configured context (KMS_ENCRYPTION_CONTEXT) is always required
+		var awsErr awserr.Error
+		if !isDefault || encryptionContext == nil || !errors.As(err, &awsErr) ||
+			awsErr.Code() != kms.ErrCodeInvalidCiphertextException {
+			return "", err
+		}
+		log.Printf("unable to decrypt with the encryption context of function %s, trying without a context", cfg.LambdaFunctionName)
+		if out, err = kmsSvc.Decrypt(&kms.DecryptInput{
+			CiphertextBlob: sDec,
+		}); err != nil {
+			return "", err
+		}
 	}
 
 	// Return a string with no leading or trailing spaces or carriage returns
 	return strings.TrimSpace(strings.TrimSuffix(string(out.Plaintext), "\n")), err
 }
 
+// getEncryptionContext will return the KMS encryption context from the configuration (KMS_ENCRYPTION_CONTEXT)
+// Defaults to the function name (AWS_LAMBDA_FUNCTION_NAME), which is the context of the Lambda console encryption helpers
+func getEncryptionContext(cfg *configuration) (encryptionContext map[string]*string, isDefault bool) {
+	if len(cfg.KMSEncryptionContext) > 0 {
+		encryptionContext = aws.StringMap(cfg.KMSEncryptionContext)
+	} else if len(cfg.LambdaFunctionName) > 0 {
+		encryptionContext = map[string]*string{"LambdaFunctionName": aws.String(cfg.LambdaFunctionName)}
+		isDefault = true
+	}
+	return
+}
+
 // getExecutionOutput will return the output details of the pipeline execution
 func getExecutionOutput(pipelineName, executionID string, pipeline codepipelineiface.CodePipelineAPI) (response *codepipeline.GetPipelineExecutionOutput, err error) {
 	if response, err = pipeline.GetPipelineExecution(&codepipeline.GetPipelineExecutionInput{
@@ -249,14 +626,76 @@ func getExecutionOutput(pipelineName, executionID string, pipeline codepipelinei
 	return
 }
 
-// getArtifact will get the artifact from a given output
-func getArtifact(executionOutput *codepipeline.GetPipelineExecutionOutput) (sourceArtifact *codepipeline.ArtifactRevision) {
-	for _, artifact := range executionOutput.PipelineExecution.ArtifactRevisions {
-		if aws.StringValue(artifact.Name) == sourceArtifactName {
-			sourceArtifact = artifact
+// getArtifact will get the source artifact from a given output
+//
+// Artifact names are checked in order of preference, if none are found and auto-detect
+// is enabled, the first source artifact (SCM revision url or S3 source) is used
+func getArtifact(executionOutput *codepipeline.GetPipelineExecutionOutput,
+	pipeline codepipelineiface.CodePipelineAPI,
+) (sourceArtifact *codepipeline.ArtifactRevision) {
+	pipelineName := aws.StringValue(executionOutput.PipelineExecution.PipelineName)
+
+	// Find the first artifact by name (in order of preference)
+	for _, name := range getSourceArtifactNames(pipelineName) {
+		for _, artifact := range executionOutput.PipelineExecution.ArtifactRevisions {
+			if aws.StringValue(artifact.Name) == name {
+				sourceArtifact = artifact
+				break
+			}
+		}
+		if sourceArtifact != nil {
 			break
 		}
 	}
+
+	// Auto-detect the artifact by the revision url (or the S3 source action)
+	if sourceArtifact == nil && getConfig().SourceArtifactAutoDetect {
+		if artifacts := getArtifacts(executionOutput, pipeline); len(artifacts) > 0 {
+			sourceArtifact = artifacts[0]
+		}
+	}
+
+	if sourceArtifact != nil {
+		log.Printf("using source artifact: %s for pipeline: %s", aws.StringValue(sourceArtifact.Name), pipelineName)
+	}
+	return
+}
+
+// getSourceArtifactNames will return the source artifact names for a pipeline
+//
+// The names of the pipeline route (ROUTING_CONFIG) and then per-pipeline names (SOURCE_ARTIFACT_MAP)
+// take precedence over the global list (SOURCE_ARTIFACT_NAMES)
+// Example: SOURCE_ARTIFACT_MAP="my-pipeline:AppSource|SourceOutput,other-pipeline:SourceOutput"
+func getSourceArtifactNames(pipelineName string) []string {
+	cfg := getConfig()
+	if route := getPipelineRoute(pipelineName); route != nil && len(route.SourceArtifactNames) > 0 {
+		return route.SourceArtifactNames
+	} else if names, ok := cfg.SourceArtifactMap[pipelineName]; ok && len(names) > 0 {
+		return strings.Split(names, "|")
+	} else if len(cfg.SourceArtifactNames) > 0 {
+		return cfg.SourceArtifactNames
+	}
+	return []string{sourceArtifactName}
+}
+
+// getArtifacts will get all the source artifacts from a given output, the artifacts with an SCM revision url
+// (GitHub or CodeCommit) and the artifacts of S3 source actions (resolved by getS3Revision)
+func getArtifacts(executionOutput *codepipeline.GetPipelineExecutionOutput,
+	pipeline codepipelineiface.CodePipelineAPI,
+) (sourceArtifacts []*codepipeline.ArtifactRevision) {
+	pipelineName := aws.StringValue(executionOutput.PipelineExecution.PipelineName)
+	for _, artifact := range executionOutput.PipelineExecution.ArtifactRevisions {
+		if len(aws.StringValue(artifact.RevisionId)) == 0 {
+			continue
+		}
+		revisionURL, err := url.Parse(aws.StringValue(artifact.RevisionUrl))
+		if err != nil {
+			continue
+		} else if isGitHubURL(revisionURL) || isCodeCommitURL(revisionURL) ||
+			isS3SourceArtifact(pipelineName, artifact, pipeline) {
+			sourceArtifacts = append(sourceArtifacts, artifact)
+		}
+	}
 	return
 }
 
@@ -266,10 +705,29 @@ func main() {
 	// Create a new AWS session
 	if awsSession == nil {
 		awsSession = session.Must(session.NewSession(&aws.Config{
-			Region: aws.String(config.AWSRegion),
+			Region: aws.String(getConfig().AWSRegion),
 		}))
 	}
 
+	// Commands (standalone http server, backfill or verify)
+	if len(os.Args) > 1 {
+		var err error
+		switch os.Args[1] {
+		case "serve":
+			err = runServer(os.Args[2:])
+		case "backfill":
+			err = runBackfill(os.Args[2:])
+		case "verify":
+			err = runVerify(os.Args[2:])
+		default:
+			err = fmt.Errorf("unknown command: %s", os.Args[1])
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	// Start lambda
-	lambda.Start(ProcessEvent)
+	lambda.Start(handleRequest)
 }
@@ -1,11 +1,15 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/codepipeline"
 	"github.com/aws/aws-sdk-go/service/codepipeline/codepipelineiface"
@@ -32,9 +36,31 @@ func (m *mockKmsClient) Decrypt(input *kms.DecryptInput) (*kms.DecryptOutput, er
 	return output, nil
 }
 
+// Mocking kms client (values encrypted with an encryption context)
+type mockKmsContextClient struct {
+	kmsiface.KMSAPI
+	calls             int
+	encryptionContext map[string]string // Context of the encrypted value (nil is no context)
+}
+
+// Decrypt is used for mocking a decryption of a KMS key (the encryption context must match)
+func (m *mockKmsContextClient) Decrypt(input *kms.DecryptInput) (*kms.DecryptOutput, error) {
+	m.calls++
+	if len(input.EncryptionContext) != len(m.encryptionContext) {
+		return nil, awserr.New(kms.ErrCodeInvalidCiphertextException, "aws will reject: encryption context", nil)
+	}
+	for key, value := range m.encryptionContext {
+		if aws.StringValue(input.EncryptionContext[key]) != value {
+			return nil, awserr.New(kms.ErrCodeInvalidCiphertextException, "aws will reject: encryption context", nil)
+		}
+	}
+	return &kms.DecryptOutput{Plaintext: []byte("context-text\n")}, nil
+}
+
 // Mocking pipeline client
 type mockCodePipelineClient struct {
 	codepipelineiface.CodePipelineAPI
+	approvals []*codepipeline.PutApprovalResultInput // Recorded approval results
 }
 
 // GetPipelineExecution is a mock request for codepipeline
@@ -72,6 +98,60 @@ func (m *mockCodePipelineClient) GetPipelineExecution(input *codepipeline.GetPip
 			RevisionSummary: aws.String("Some commit message"),
 			RevisionUrl:     aws.String("not a url"),
 		})
+	} else if aws.StringValue(input.PipelineName) == "s3-source" {
+		artifacts = append(artifacts, &codepipeline.ArtifactRevision{
+			Name:       aws.String("SourceCode"),
+			RevisionId: aws.String(aws.StringValue(input.PipelineExecutionId)),
+		})
+	} else if aws.StringValue(input.PipelineName) == "s3-source-summary" {
+		artifacts = append(artifacts, &codepipeline.ArtifactRevision{
+			Name:            aws.String("SourceCode"),
+			RevisionId:      aws.String("version-1"),
+			RevisionSummary: aws.String(`{"repository":"mrz1836/codepipeline-to-github","commit":"25c0c3e61c4db2c2cde8b163b3ad096875c1ce08"}`),
+		})
+	} else if aws.StringValue(input.PipelineName) == "s3-source-multi" {
+		artifacts = append(artifacts, &codepipeline.ArtifactRevision{
+			Name:       aws.String("SourceCode"),
+			RevisionId: aws.String(aws.StringValue(input.PipelineExecutionId)),
+		}, &codepipeline.ArtifactRevision{
+			Name:            aws.String("AppSource"),
+			RevisionId:      aws.String("9b2a1e4f5c6d7e8f9a0b1c2d3e4f5a6b7c8d9e0f"),
+			RevisionSummary: aws.String("Some app commit message"),
+			RevisionUrl:     aws.String("https://github.com/mrz1836/app/commit/9b2a1e4f5c6d7e8f9a0b1c2d3e4f5a6b7c8d9e0f"),
+		}, &codepipeline.ArtifactRevision{
+			Name:       aws.String("BuildOutput"),
+			RevisionId: aws.String("some-s3-version-id"),
+		})
+	} else if aws.StringValue(input.PipelineName) == "custom-artifact-name" {
+		artifacts = append(artifacts, &codepipeline.ArtifactRevision{
+			Name:       aws.String("BuildOutput"),
+			RevisionId: aws.String("some-s3-version-id"),
+		}, &codepipeline.ArtifactRevision{
+			Name:            aws.String("AppSource"),
+			RevisionId:      aws.String("25c0c3e61c4db2c2cde8b163b3ad096875c1ce08"),
+			RevisionSummary: aws.String("Some commit message"),
+			RevisionUrl:     aws.String("https://github.com/mrz1836/codepipeline-to-github/commit/25c0c3e61c4db2c2cde8b163b3ad096875c1ce08"),
+		})
+	} else if aws.StringValue(input.PipelineName) == "multi-source" {
+		artifacts = append(artifacts, &codepipeline.ArtifactRevision{
+			Name:            aws.String("SourceCode"),
+			RevisionId:      aws.String("25c0c3e61c4db2c2cde8b163b3ad096875c1ce08"),
+			RevisionSummary: aws.String("Some commit message"),
+			RevisionUrl:     aws.String("https://github.com/mrz1836/codepipeline-to-github/commit/25c0c3e61c4db2c2cde8b163b3ad096875c1ce08"),
+		}, &codepipeline.ArtifactRevision{
+			Name:            aws.String("InfraSource"),
+			RevisionId:      aws.String("9b2a1e4f5c6d7e8f9a0b1c2d3e4f5a6b7c8d9e0f"),
+			RevisionSummary: aws.String("Some infra commit message"),
+			RevisionUrl:     aws.String("https://us-east-1.console.aws.amazon.com/codesuite/settings/connections/redirect?connectionArn=arn&referenceType=COMMIT&FullRepositoryId=mrz1836/infra&Commit=9b2a1e4f5c6d7e8f9a0b1c2d3e4f5a6b7c8d9e0f"),
+		}, &codepipeline.ArtifactRevision{
+			Name:            aws.String("ConfigSource"),
+			RevisionId:      aws.String("0f1e2d3c4b5a69788796a5b4c3d2e1f00f1e2d3c"),
+			RevisionSummary: aws.String("Some config commit message"),
+			RevisionUrl:     aws.String("https://us-east-1.console.aws.amazon.com/codesuite/codecommit/repositories/shared-config/commit/0f1e2d3c4b5a69788796a5b4c3d2e1f00f1e2d3c?region=us-east-1"),
+		}, &codepipeline.ArtifactRevision{
+			Name:       aws.String("BuildOutput"),
+			RevisionId: aws.String("some-s3-version-id"),
+		})
 	} else {
 		artifacts = append(artifacts, &codepipeline.ArtifactRevision{
 			Name:            aws.String("SourceCode"),
@@ -88,6 +168,8 @@ func (m *mockCodePipelineClient) GetPipelineExecution(input *codepipeline.GetPip
 		defaultStatus = aws.String("Succeeded")
 	} else if aws.StringValue(input.PipelineName) == "status-fail" {
 		defaultStatus = aws.String("Failure")
+	} else if strings.HasPrefix(aws.StringValue(input.PipelineName), "backfill") {
+		defaultStatus = aws.String(strings.Split(aws.StringValue(input.PipelineExecutionId), "-")[0]) // IE: Succeeded-1
 	}
 
 	// Create a valid execution output
@@ -100,6 +182,140 @@ func (m *mockCodePipelineClient) GetPipelineExecution(input *codepipeline.GetPip
 		},
 	}
 
+	// V2 pipeline triggered by a pull request
+	if aws.StringValue(input.PipelineName) == "v2-pull-request" {
+		output.PipelineExecution.Trigger = &codepipeline.ExecutionTrigger{
+			TriggerDetail: aws.String("arn:aws:codestar-connections:us-east-1:123456789012:connection/abc"),
+			TriggerType:   aws.String(codepipeline.TriggerTypeWebhookV2),
+		}
+	}
+
+	return output, nil
+}
+
+// ListPipelinesPages is a mock request for codepipeline
+func (m *mockCodePipelineClient) ListPipelinesPages(_ *codepipeline.ListPipelinesInput,
+	fn func(*codepipeline.ListPipelinesOutput, bool) bool,
+) error {
+	fn(&codepipeline.ListPipelinesOutput{
+		Pipelines: []*codepipeline.PipelineSummary{
+			{Name: aws.String("backfill")}, {Name: aws.String("backfill-retry")}, {Name: aws.String("v2-pull-request")},
+		},
+	}, true)
+	return nil
+}
+
+// ListPipelineExecutionsPages is a mock request for codepipeline
+func (m *mockCodePipelineClient) ListPipelineExecutionsPages(input *codepipeline.ListPipelineExecutionsInput,
+	fn func(*codepipeline.ListPipelineExecutionsOutput, bool) bool,
+) error {
+
+	// Missing pipeline name
+	if len(aws.StringValue(input.PipelineName)) == 0 {
+		return fmt.Errorf("aws will reject: missing pipeline name")
+	}
+
+	// Recent executions (newest first) over two pages
+	if aws.StringValue(input.PipelineName) == "backfill" {
+		now := time.Now()
+		if fn(&codepipeline.ListPipelineExecutionsOutput{
+			PipelineExecutionSummaries: []*codepipeline.PipelineExecutionSummary{
+				{PipelineExecutionId: aws.String("InProgress-1"), StartTime: aws.Time(now.Add(-time.Minute)), Status: aws.String("InProgress")},
+				{PipelineExecutionId: aws.String("Succeeded-2"), StartTime: aws.Time(now.Add(-time.Hour)), Status: aws.String("Succeeded")},
+			},
+		}, false) {
+			fn(&codepipeline.ListPipelineExecutionsOutput{
+				PipelineExecutionSummaries: []*codepipeline.PipelineExecutionSummary{
+					{PipelineExecutionId: aws.String("Failed-3"), StartTime: aws.Time(now.Add(-48 * time.Hour)), Status: aws.String("Failed")},
+				},
+			}, true)
+		}
+		return nil
+	}
+
+	// Finished retries of the same commit (newest first)
+	if aws.StringValue(input.PipelineName) == "backfill-retry" {
+		now := time.Now()
+		fn(&codepipeline.ListPipelineExecutionsOutput{
+			PipelineExecutionSummaries: []*codepipeline.PipelineExecutionSummary{
+				{PipelineExecutionId: aws.String("Succeeded-1"), StartTime: aws.Time(now.Add(-time.Hour)), Status: aws.String("Succeeded")},
+				{PipelineExecutionId: aws.String("Failed-2"), StartTime: aws.Time(now.Add(-48 * time.Hour)), Status: aws.String("Failed")},
+			},
+		}, true)
+		return nil
+	}
+
+	fn(&codepipeline.ListPipelineExecutionsOutput{
+		PipelineExecutionSummaries: []*codepipeline.PipelineExecutionSummary{{
+			PipelineExecutionId: aws.String("12345"),
+			SourceRevisions: []*codepipeline.SourceRevision{{
+				ActionName:      aws.String("Source"),
+				RevisionId:      aws.String("f00dbabe61c4db2c2cde8b163b3ad096875c1ce0"),
+				RevisionSummary: aws.String(`{"ProviderType":"GitHub","CommitMessage":"Some commit message","PullRequestId":"42"}`),
+				RevisionUrl:     aws.String("https://github.com/mrz1836/codepipeline-to-github/commit/f00dbabe61c4db2c2cde8b163b3ad096875c1ce0"),
+			}},
+			Status: aws.String("InProgress"),
+		}},
+	}, true)
+	return nil
+}
+
+// GetPipeline is a mock request for codepipeline
+func (m *mockCodePipelineClient) GetPipeline(input *codepipeline.GetPipelineInput) (*codepipeline.GetPipelineOutput, error) {
+
+	// Missing pipeline name
+	if len(aws.StringValue(input.Name)) == 0 {
+		return nil, fmt.Errorf("aws will reject: missing pipeline name")
+	}
+
+	// Source action (S3 or GitHub)
+	sourceAction := &codepipeline.ActionDeclaration{
+		ActionTypeId: &codepipeline.ActionTypeId{
+			Category: aws.String("Source"),
+			Owner:    aws.String("ThirdParty"),
+			Provider: aws.String("GitHub"),
+		},
+		Name:            aws.String("Source"),
+		OutputArtifacts: []*codepipeline.OutputArtifact{{Name: aws.String("SourceCode")}},
+	}
+	if strings.HasPrefix(aws.StringValue(input.Name), "s3-source") {
+		sourceAction.ActionTypeId.Owner = aws.String("AWS")
+		sourceAction.ActionTypeId.Provider = aws.String("S3")
+		sourceAction.Configuration = map[string]*string{
+			"S3Bucket":    aws.String("artifact-bucket"),
+			"S3ObjectKey": aws.String("app/source.zip"),
+		}
+	}
+
+	output := &codepipeline.GetPipelineOutput{
+		Pipeline: &codepipeline.PipelineDeclaration{
+			Name: input.Name,
+			Stages: []*codepipeline.StageDeclaration{{
+				Actions: []*codepipeline.ActionDeclaration{sourceAction},
+				Name:    aws.String("Source"),
+			}},
+		},
+	}
+
+	// Manual approval action
+	if aws.StringValue(input.Name) == "approval" {
+		output.Pipeline.Stages = append(output.Pipeline.Stages, &codepipeline.StageDeclaration{
+			Actions: []*codepipeline.ActionDeclaration{{
+				ActionTypeId: &codepipeline.ActionTypeId{
+					Category: aws.String("Approval"),
+					Owner:    aws.String("AWS"),
+					Provider: aws.String("Manual"),
+				},
+				Configuration: map[string]*string{
+					"CustomData":         aws.String("Deploy to production?"),
+					"ExternalEntityLink": aws.String("https://staging.example.com"),
+				},
+				Name: aws.String("Manual"),
+			}},
+			Name: aws.String("Approve"),
+		})
+	}
+
 	return output, nil
 }
 
@@ -278,7 +494,7 @@ func TestGetArtifact(t *testing.T) {
 		t.Fatal("response is nil and was expected to be a pointer")
 	}
 
-	artifact := getArtifact(response)
+	artifact := getArtifact(response, mockPipeline)
 	if artifact == nil {
 		t.Fatal("artifact was nil, expected a pointer")
 	}
@@ -286,13 +502,112 @@ func TestGetArtifact(t *testing.T) {
 	// Test an invalid artifact name
 	response, _ = getExecutionOutput("bad-artifact-name", "12345", mockPipeline)
 
-	artifact = getArtifact(response)
+	artifact = getArtifact(response, mockPipeline)
 	if artifact != nil {
 		t.Fatal("artifact was not nil, expected artifact to be nil")
 	}
 
 }
 
+// TestGetArtifactConfigured will test getting an artifact using configured or auto-detected names
+func TestGetArtifactConfigured(t *testing.T) {
+	mockPipeline := &mockCodePipelineClient{}
+
+	response, err := getExecutionOutput("custom-artifact-name", "12345", mockPipeline)
+	if err != nil {
+		t.Fatal("error should not have occurred", err.Error())
+	}
+
+	// Default name is not found
+	if artifact := getArtifact(response, mockPipeline); artifact != nil {
+		t.Fatal("artifact was not nil, expected artifact to be nil")
+	}
+
+	// Configured list of names (in order of preference)
+	setConfig(t, func(c *loadedConfiguration) {
+		c.SourceArtifactNames = []string{"SourceOutput", "AppSource"}
+	})
+	if artifact := getArtifact(response, mockPipeline); artifact == nil || aws.StringValue(artifact.Name) != "AppSource" {
+		t.Fatal("expected the AppSource artifact", artifact)
+	}
+
+	// Per-pipeline names take precedence
+	setConfig(t, func(c *loadedConfiguration) {
+		c.SourceArtifactMap = map[string]string{"custom-artifact-name": "Missing|BuildOutput"}
+	})
+	if artifact := getArtifact(response, mockPipeline); artifact == nil || aws.StringValue(artifact.Name) != "BuildOutput" {
+		t.Fatal("expected the BuildOutput artifact", artifact)
+	}
+
+	// Auto-detect by the revision url
+	setConfig(t, func(c *loadedConfiguration) {
+		c.SourceArtifactMap = map[string]string{"custom-artifact-name": "Missing"}
+		c.SourceArtifactAutoDetect = true
+	})
+	if artifact := getArtifact(response, mockPipeline); artifact == nil || aws.StringValue(artifact.Name) != "AppSource" {
+		t.Fatal("expected the AppSource artifact", artifact)
+	}
+}
+
+// TestGetArtifacts will test getting all the SCM artifacts from an execution output
+func TestGetArtifacts(t *testing.T) {
+	t.Parallel()
+
+	mockPipeline := &mockCodePipelineClient{}
+
+	// Multiple source artifacts (skips artifacts without a revision url)
+	response, err := getExecutionOutput("multi-source", "12345", mockPipeline)
+	if err != nil {
+		t.Fatal("error should not have occurred", err.Error())
+	}
+	artifacts := getArtifacts(response, mockPipeline)
+	if len(artifacts) != 3 {
+		t.Fatal("expected 3 artifacts", len(artifacts))
+	}
+
+	// Invalid artifact url
+	response, _ = getExecutionOutput("bad-artifact-url", "12345", mockPipeline)
+	if artifacts = getArtifacts(response, mockPipeline); len(artifacts) != 0 {
+		t.Fatal("expected no artifacts", len(artifacts))
+	}
+}
+
+// TestGetRevisions will test getting the source revisions from a pipeline execution
+func TestGetRevisions(t *testing.T) {
+
+	mockPipeline := &mockCodePipelineClient{}
+
+	// Only the source artifact
+	setConfig(t, func(c *loadedConfiguration) {
+		c.AllSourceArtifacts = false
+	})
+	revisions, status, err := getRevisions("multi-source", "12345", mockPipeline, nil)
+	if err != nil {
+		t.Fatal("error occurred", err.Error())
+	} else if len(revisions) != 1 {
+		t.Fatal("expected a single revision", len(revisions))
+	} else if status != "pending" {
+		t.Fatal("status value was not as expected", status)
+	}
+
+	// Every source artifact (default)
+	setConfig(t, func(c *loadedConfiguration) {
+		c.AllSourceArtifacts = true
+	})
+	if revisions, _, err = getRevisions("multi-source", "12345", mockPipeline, nil); err != nil {
+		t.Fatal("error occurred", err.Error())
+	} else if len(revisions) != 3 {
+		t.Fatal("expected 3 revisions", len(revisions))
+	} else if revisions[1].ArtifactName != "InfraSource" || revisions[1].Commit != "9b2a1e4f5c6d7e8f9a0b1c2d3e4f5a6b7c8d9e0f" {
+		t.Fatal("revision was not as expected", revisions[1])
+	}
+
+	// Missing execution
+	if _, _, err = getRevisions("nil", "12345", mockPipeline, nil); err == nil {
+		t.Fatal("error should have occurred")
+	}
+}
+
 // TestGetCommit will test getting a commit from a pipeline execution
 func TestGetCommit(t *testing.T) {
 	t.Parallel()
@@ -330,14 +645,14 @@ func TestGetCommit(t *testing.T) {
 	}
 }
 
-// TestDecryptString will test decryptString()
-func TestDecryptString(t *testing.T) {
+// TestDecryptConfigString will test decryptConfigString()
+func TestDecryptConfigString(t *testing.T) {
 	t.Parallel()
 
 	mockKms := &mockKmsClient{}
 
 	// Valid decryption
-	decrypted, err := decryptString(mockKms, "dGhpcyBpcyBzYW5mb3VuZHJ5IGxpbnV4IHR1dG9yaWFsCg==")
+	decrypted, err := decryptConfigString(&configuration{}, mockKms, "dGhpcyBpcyBzYW5mb3VuZHJ5IGxpbnV4IHR1dG9yaWFsCg==")
 	if err != nil {
 		t.Fatal("error occurred", err.Error())
 	} else if decrypted != "some-encrypted-text" {
@@ -345,18 +660,59 @@ func TestDecryptString(t *testing.T) {
 	}
 
 	// Invalid base64
-	_, err = decryptString(mockKms, "invalid-base-64")
+	_, err = decryptConfigString(&configuration{}, mockKms, "invalid-base-64")
 	if err == nil {
 		t.Fatal("error should have occurred")
 	}
 
 	// Invalid value
-	_, err = decryptString(mockKms, "")
+	_, err = decryptConfigString(&configuration{}, mockKms, "")
 	if err == nil {
 		t.Fatal("error should have occurred")
 	}
 }
 
+// TestDecryptConfigStringEncryptionContext will test decryptConfigString() with an encryption context
+func TestDecryptConfigStringEncryptionContext(t *testing.T) {
+	t.Parallel()
+
+	const encrypted = "dGhpcyBpcyBzYW5mb3VuZHJ5IGxpbnV4IHR1dG9yaWFsCg=="
+	var tests = []struct {
+		name              string
+		configured        map[string]string
+		functionName      string
+		encryptionContext map[string]string
+		expectedCalls     int
+		expectedError     bool
+	}{
+		{"no context", nil, "", nil, 1, false},
+		{"function name", nil, "codepipeline-to-github", map[string]string{"LambdaFunctionName": "codepipeline-to-github"}, 1, false},
+		{"another function", nil, "codepipeline-to-github", map[string]string{"LambdaFunctionName": "other-function"}, 2, true},
+		{"encrypted without a context", nil, "codepipeline-to-github", nil, 2, false},
+		{"configured", map[string]string{"app": "status"}, "codepipeline-to-github", map[string]string{"app": "status"}, 1, false},
+		{"configured without a context", map[string]string{"app": "status"}, "", nil, 1, true},
+		{"configured mismatch", map[string]string{"app": "other"}, "", map[string]string{"app": "status"}, 1, true},
+	}
+
+	for _, test := range tests {
+		cfg := &configuration{
+			KMSEncryptionContext: test.configured,
+			LambdaFunctionName:   test.functionName,
+		}
+		mockKms := &mockKmsContextClient{encryptionContext: test.encryptionContext}
+		decrypted, err := decryptConfigString(cfg, mockKms, encrypted)
+		if err == nil && test.expectedError {
+			t.Errorf("%s Failed: [%s] expected to throw an error, but no error", t.Name(), test.name)
+		} else if err != nil && !test.expectedError {
+			t.Errorf("%s Failed: [%s] error occurred [%s]", t.Name(), test.name, err.Error())
+		} else if err == nil && decrypted != "context-text" {
+			t.Errorf("%s Failed: [%s] expected [context-text] got [%s]", t.Name(), test.name, decrypted)
+		} else if mockKms.calls != test.expectedCalls {
+			t.Errorf("%s Failed: [%s] expected [%d] calls got [%d]", t.Name(), test.name, test.expectedCalls, mockKms.calls)
+		}
+	}
+}
+
 // TestLoadConfiguration will test loadConfiguration()
 func TestLoadConfiguration(t *testing.T) {
 	mockKms := &mockKmsClient{}
@@ -403,9 +759,62 @@ func TestLoadConfiguration(t *testing.T) {
 	err = loadConfiguration(mockKms)
 	if err != nil {
 		t.Fatal("error occurred", err.Error())
-	} else if len(config.GithubAccessToken) == 0 {
+	} else if len(getConfig().GithubAccessToken) == 0 {
 		t.Fatal("missing token value")
-	} else if config.GithubAccessToken != "some-encrypted-text" {
-		t.Fatal("invalid token value", config.GithubAccessToken)
+	} else if getConfig().GithubAccessToken != "some-encrypted-text" {
+		t.Fatal("invalid token value", getConfig().GithubAccessToken)
+	}
+}
+
+// TestEventEnvelope will test parsing the full event envelope, getRegion() and getEventSession()
+func TestEventEnvelope(t *testing.T) {
+
+	setConfig(t, func(c *loadedConfiguration) {
+		c.AWSRegion = "us-east-1"
+		c.CrossAccountRoleName = ""
+	})
+	if awsSession == nil {
+		awsSession = session.Must(session.NewSession(&aws.Config{
+			Region: aws.String("us-east-1"),
+		}))
+	}
+
+	for _, name := range []string{"started", "build-succeeded"} {
+		raw, err := os.ReadFile("events/" + name + "-event.json")
+		if err != nil {
+			t.Fatal("error occurred", err.Error())
+		}
+		var ev event
+		if err = json.Unmarshal(raw, &ev); err != nil {
+			t.Fatalf("%s Failed: [%s] error occurred [%s]", t.Name(), name, err.Error())
+		} else if ev.ID != "CWE-event-id" || ev.Account != "1234567890123" || ev.Region != "us-east-1" ||
+			ev.Time.IsZero() || len(ev.Detail.Version) == 0 {
+			t.Errorf("%s Failed: [%s] envelope was not as expected [%+v]", t.Name(), name, ev)
+		}
+		if name == "started" && (ev.Detail.ExecutionTrigger == nil ||
+			ev.Detail.ExecutionTrigger.TriggerType != triggerTypeStartPipelineExecution) {
+			t.Errorf("%s Failed: [%s] missing execution trigger", t.Name(), name)
+		}
+	}
+
+	if region := getRegion(event{Region: "eu-west-1"}); region != "eu-west-1" {
+		t.Fatal("expected the event region", region)
+	} else if region = getRegion(event{}); region != "us-east-1" {
+		t.Fatal("expected the configured region", region)
+	}
+
+	eventSession := getEventSession(event{Account: "210987654321", Region: "eu-west-1"})
+	if aws.StringValue(eventSession.Config.Region) != "eu-west-1" {
+		t.Fatal("expected the event region", aws.StringValue(eventSession.Config.Region))
+	} else if eventSession.Config.Credentials != awsSession.Config.Credentials {
+		t.Fatal("expected the default credentials without a cross account role")
+	}
+
+	setConfig(t, func(c *loadedConfiguration) {
+		c.CrossAccountRoleName = "codepipeline-to-github"
+	})
+	setFunctionAccountClient(t, "1234567890123")
+	if eventSession = getEventSession(event{Account: "210987654321", Region: "eu-west-1"}); eventSession.Config.Credentials == awsSession.Config.Credentials {
+		t.Fatal("expected assumed role credentials for the event account")
 	}
 }
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/codecommit"
+)
+
+// Status event defaults (generic input for non-AWS CI systems, see docs/status-event.md)
+const (
+	detailTypeStatusUpdate = "Status Update"
+	eventKindStatus        = "status"
+	statusEventContext     = "continuous-integration/external"
+	statusEventVersion     = "1"
+)
+
+// statusEventDetail is the detail of a status event (the public schema, separate from the AWS event detail)
+type statusEventDetail struct {
+	Context     string         `json:"context,omitempty"`
+	Description string         `json:"description,omitempty"`
+	ExecutionID string         `json:"execution-id,omitempty"`
+	Pipeline    string         `json:"pipeline,omitempty"`
+	Repository  string         `json:"repository"`
+	SHA         string         `json:"sha"`
+	Stages      []*statusStage `json:"stages,omitempty"`
+	State       string         `json:"state"`
+	TargetURL   string         `json:"target_url,omitempty"`
+	Version     json.Number    `json:"version"`
+}
+
+// statusStage is an optional stage of a status event (posted with its own context)
+type statusStage struct {
+	Description string `json:"description,omitempty"`
+	Name        string `json:"name"`
+	State       string `json:"state"`
+	TargetURL   string `json:"target_url,omitempty"`
+}
+
+// validateStatusEvent will check the required parameters of a status event (schema version 1)
+func validateStatusEvent(ev event) error {
+	eventDetail, err := getStatusEventDetail(ev)
+	if err != nil {
+		return err
+	}
+	if eventDetail.Version.String() != statusEventVersion {
+		return permanent(fmt.Errorf("unsupported status event version: %s", eventDetail.Version))
+	}
+	if _, _, err = parseRepository(eventDetail.Repository); err != nil {
+		return permanent(err)
+	}
+	if !commitSHA.MatchString(eventDetail.SHA) {
+		return permanent(fmt.Errorf("invalid event param sha: %s", eventDetail.SHA))
+	}
+	if !isGitHubState(eventDetail.State) {
+		return permanent(fmt.Errorf("invalid event param state: %s", eventDetail.State))
+	}
+	if err = validateTargetURL(eventDetail.TargetURL); err != nil {
+		return err
+	}
+	for _, stage := range eventDetail.Stages {
+		if len(stage.Name) == 0 {
+			return permanent(errors.New("missing stage param name"))
+		} else if !isGitHubState(stage.State) {
+			return permanent(fmt.Errorf("invalid state: %s for stage: %s", stage.State, stage.Name))
+		} else if err = validateTargetURL(stage.TargetURL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// processStatusEvent will publish the status (and a status per stage) without a CodePipeline lookup
+func processStatusEvent(ev event) error {
+	eventDetail, err := getStatusEventDetail(ev)
+	if err != nil {
+		return err
+	}
+
+	// Get the publishers (GitHub, CodeCommit, chat, webhooks)
+	publishers, err := getPublishers(codecommit.New(getEventSession(ev)), "")
+	if err != nil {
+		return err
+	}
+
+	// Publish the status, then the stages (collect the errors per context)
+	var errs []error
+	for _, update := range getStatusEventUpdates(eventDetail) {
+		if err = publishStatus(context.Background(), publishers, update); err != nil {
+			errs = append(errs, fmt.Errorf("context %s: %w", update.Context, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// getStatusEventDetail will decode the detail of a status event (schema version 1)
+func getStatusEventDetail(ev event) (*statusEventDetail, error) {
+	var eventDetail statusEventDetail
+	if err := json.Unmarshal(ev.RawDetail, &eventDetail); err != nil {
+		return nil, permanent(fmt.Errorf("invalid status event detail: %w", err))
+	}
+	return &eventDetail, nil
+}
+
+// getStatusEventUpdates will return the status updates of a (validated) status event
+// Stages use the context of the event with the stage name (IE: continuous-integration/jenkins/build)
+func getStatusEventUpdates(eventDetail *statusEventDetail) (updates []*statusUpdate) {
+	owner, repo, _ := parseRepository(eventDetail.Repository)
+	statusContext := eventDetail.Context
+	if len(statusContext) == 0 {
+		statusContext = statusEventContext
+	}
+
+	// The status of the event
+	updates = append(updates, &statusUpdate{
+		Commit:      eventDetail.SHA,
+		Context:     statusContext,
+		Description: getStatusEventDescription(eventDetail.Description, "Build", eventDetail.State),
+		ExecutionID: eventDetail.ExecutionID,
+		Owner:       owner,
+		Pipeline:    eventDetail.Pipeline,
+		Provider:    providerGitHub,
+		Repository:  repo,
+		State:       eventDetail.State,
+		TargetURL:   eventDetail.TargetURL,
+	})
+
+	// A status per stage (defaults to the url of the event)
+	for _, stage := range eventDetail.Stages {
+		targetURL := stage.TargetURL
+		if len(targetURL) == 0 {
+			targetURL = eventDetail.TargetURL
+		}
+		updates = append(updates, &statusUpdate{
+			Commit:      eventDetail.SHA,
+			Context:     statusContext + "/" + stage.Name,
+			Description: getStatusEventDescription(stage.Description, "Stage "+stage.Name, stage.State),
+			ExecutionID: eventDetail.ExecutionID,
+			Owner:       owner,
+			Pipeline:    eventDetail.Pipeline,
+			Provider:    providerGitHub,
+			Repository:  repo,
+			State:       stage.State,
+			TargetURL:   targetURL,
+		})
+	}
+	return
+}
+
+// getStatusEventDescription will return the description, or a default based on the state (IE: Stage build success)
+func getStatusEventDescription(description, name, state string) string {
+	if len(description) == 0 {
+		return getEventDescription(name, state)
+	} else if len(description) > 140 {
+		return description[:137] + "..."
+	}
+	return description
+}
+
+// isGitHubState will return true if the state is a valid GitHub commit status state
+func isGitHubState(state string) bool {
+	switch state {
+	case "error", "failure", "pending", "success":
+		return true
+	default:
+		return false
+	}
+}
+
+// validateTargetURL will check that the (optional) target url is an absolute http(s) url
+func validateTargetURL(targetURL string) error {
+	if len(targetURL) == 0 {
+		return nil
+	}
+	if parsed, err := url.Parse(targetURL); err != nil || len(parsed.Host) == 0 ||
+		(!strings.EqualFold(parsed.Scheme, "https") && !strings.EqualFold(parsed.Scheme, "http")) {
+		return permanent(fmt.Errorf("invalid target_url: %s", targetURL))
+	}
+	return nil
+}
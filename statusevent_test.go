@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newStatusEventDetail will return a valid status event detail (schema version 1)
+func newStatusEventDetail() *statusEventDetail {
+	return &statusEventDetail{
+		Context:    "continuous-integration/jenkins",
+		Repository: "mrz1836/codepipeline-to-github",
+		SHA:        "25c0c3e61c4db2c2cde8b163b3ad096875c1ce08",
+		Stages: []*statusStage{
+			{Name: "test", State: "success"},
+			{Name: "deploy", State: "failure", TargetURL: "https://jenkins.example.com/job/42/deploy"},
+		},
+		State:     "failure",
+		TargetURL: "https://jenkins.example.com/job/42",
+		Version:   statusEventVersion,
+	}
+}
+
+// getStatusEventBody will return the raw status event (as sent by a CI system)
+func getStatusEventBody(t *testing.T, eventDetail *statusEventDetail) []byte {
+	body, err := json.Marshal(map[string]interface{}{
+		"detail":      eventDetail,
+		"detail-type": detailTypeStatusUpdate,
+	})
+	if err != nil {
+		t.Fatal("error occurred", err.Error())
+	}
+	return body
+}
+
+// newStatusEvent will return the decoded status event
+func newStatusEvent(t *testing.T, eventDetail *statusEventDetail) (ev event) {
+	if err := json.Unmarshal(getStatusEventBody(t, eventDetail), &ev); err != nil {
+		t.Fatal("error occurred", err.Error())
+	}
+	return
+}
+
+// TestValidateStatusEvent will test validateStatusEvent()
+func TestValidateStatusEvent(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		name          string
+		change        func(d *statusEventDetail)
+		expectedError bool
+	}{
+		{"valid", func(d *statusEventDetail) {}, false},
+		{"repository url", func(d *statusEventDetail) { d.Repository = "https://github.com/mrz1836/codepipeline-to-github.git" }, false},
+		{"no stages", func(d *statusEventDetail) { d.Stages = nil }, false},
+		{"no target url", func(d *statusEventDetail) { d.TargetURL = "" }, false},
+		{"missing version", func(d *statusEventDetail) { d.Version = "" }, true},
+		{"unsupported version", func(d *statusEventDetail) { d.Version = "2" }, true},
+		{"missing repository", func(d *statusEventDetail) { d.Repository = "" }, true},
+		{"invalid repository", func(d *statusEventDetail) { d.Repository = "codepipeline-to-github" }, true},
+		{"short sha", func(d *statusEventDetail) { d.SHA = "25c0c3e" }, true},
+		{"invalid state", func(d *statusEventDetail) { d.State = "SUCCEEDED" }, true},
+		{"invalid target url", func(d *statusEventDetail) { d.TargetURL = "javascript:alert(1)" }, true},
+		{"missing stage name", func(d *statusEventDetail) { d.Stages[0].Name = "" }, true},
+		{"invalid stage state", func(d *statusEventDetail) { d.Stages[0].State = "done" }, true},
+		{"invalid stage url", func(d *statusEventDetail) { d.Stages[1].TargetURL = "/relative" }, true},
+	}
+
+	for _, test := range tests {
+		eventDetail := newStatusEventDetail()
+		test.change(eventDetail)
+		err := validateStatusEvent(newStatusEvent(t, eventDetail))
+		if err == nil && test.expectedError {
+			t.Errorf("%s Failed: [%s] expected to throw an error, but no error", t.Name(), test.name)
+		} else if err != nil && !test.expectedError {
+			t.Errorf("%s Failed: [%s] error occurred [%s]", t.Name(), test.name, err.Error())
+		} else if err != nil && !isPermanentError(err) {
+			t.Errorf("%s Failed: [%s] expected a permanent error [%s]", t.Name(), test.name, err.Error())
+		}
+	}
+}
+
+// TestGetStatusEventDetail will test getStatusEventDetail()
+func TestGetStatusEventDetail(t *testing.T) {
+	t.Parallel()
+
+	// The status fields are only in the status event detail (not the AWS event detail)
+	ev := newStatusEvent(t, newStatusEventDetail())
+	eventDetail, err := getStatusEventDetail(ev)
+	if err != nil {
+		t.Fatal("error occurred", err.Error())
+	} else if eventDetail.SHA != "25c0c3e61c4db2c2cde8b163b3ad096875c1ce08" || len(eventDetail.Stages) != 2 {
+		t.Fatal("status event detail was not as expected", eventDetail)
+	}
+
+	// Invalid detail
+	ev.RawDetail = json.RawMessage(`{"stages":"test"}`)
+	if _, err = getStatusEventDetail(ev); err == nil || !isPermanentError(err) {
+		t.Fatal("expected a permanent error", err)
+	}
+}
+
+// TestGetStatusEventUpdates will test getStatusEventUpdates()
+func TestGetStatusEventUpdates(t *testing.T) {
+	t.Parallel()
+
+	eventDetail := newStatusEventDetail()
+	updates := getStatusEventUpdates(eventDetail)
+	if len(updates) != 3 {
+		t.Fatal("expected the status and a status per stage", len(updates))
+	}
+
+	var tests = []struct {
+		expectedContext     string
+		expectedDescription string
+		expectedState       string
+		expectedTargetURL   string
+	}{
+		{"continuous-integration/jenkins", "Build failure", "failure", "https://jenkins.example.com/job/42"},
+		{"continuous-integration/jenkins/test", "Stage test success", "success", "https://jenkins.example.com/job/42"},
+		{"continuous-integration/jenkins/deploy", "Stage deploy failure", "failure", "https://jenkins.example.com/job/42/deploy"},
+	}
+
+	for i, test := range tests {
+		update := updates[i]
+		if update.Context != test.expectedContext || update.Description != test.expectedDescription ||
+			update.State != test.expectedState || update.TargetURL != test.expectedTargetURL {
+			t.Errorf("%s Failed: [%d] expected [%s/%s/%s/%s] got [%s/%s/%s/%s]", t.Name(), i,
+				test.expectedContext, test.expectedDescription, test.expectedState, test.expectedTargetURL,
+				update.Context, update.Description, update.State, update.TargetURL)
+		} else if update.Owner != "mrz1836" || update.Repository != "codepipeline-to-github" || update.Provider != providerGitHub {
+			t.Errorf("%s Failed: [%d] repository was not as expected [%s/%s]", t.Name(), i, update.Owner, update.Repository)
+		}
+	}
+
+	// Default context and description
+	eventDetail.Context = ""
+	eventDetail.Description = strings.Repeat("a", 200)
+	if update := getStatusEventUpdates(eventDetail)[0]; update.Context != statusEventContext || len(update.Description) != 140 {
+		t.Fatal("expected the default context and a truncated description", update.Context, len(update.Description))
+	}
+}
+
+// TestProcessStatusEvent will test ProcessEvent() with a status event against a GitHub stand-in
+func TestProcessStatusEvent(t *testing.T) {
+	setHTTPEnvironment(t, httpAuthSecret)
+
+	var lock sync.Mutex
+	var posted []*payload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/mrz1836/codepipeline-to-github/statuses/25c0c3e61c4db2c2cde8b163b3ad096875c1ce08" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var status payload
+		_ = json.NewDecoder(r.Body).Decode(&status)
+		lock.Lock()
+		posted = append(posted, &status)
+		lock.Unlock()
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	_ = os.Setenv("GITHUB_API_URL", server.URL)
+	_ = os.Setenv("PUBLISHERS", publisherGitHub)
+	resetConfiguration()
+	defer func() {
+		_ = os.Unsetenv("GITHUB_API_URL")
+		_ = os.Unsetenv("PUBLISHERS")
+	}()
+
+	if err := ProcessEvent(newStatusEvent(t, newStatusEventDetail())); err != nil {
+		t.Fatal("error occurred", err.Error())
+	} else if len(posted) != 3 {
+		t.Fatal("expected the status and a status per stage to be posted", len(posted))
+	} else if posted[0].Context != "continuous-integration/jenkins" || posted[0].State != "failure" {
+		t.Fatal("status was not as expected", posted[0])
+	}
+
+	// Invalid events are never posted
+	eventDetail := newStatusEventDetail()
+	eventDetail.State = "unknown"
+	if err := ProcessEvent(newStatusEvent(t, eventDetail)); err == nil || !isPermanentError(err) {
+		t.Fatal("expected a permanent error", err)
+	}
+}
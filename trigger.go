@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/codepipeline"
+	"github.com/aws/aws-sdk-go/service/codepipeline/codepipelineiface"
+)
+
+// Trigger defaults
+const (
+	maxExecutionSummaryPages          = 5
+	triggerTypeCloudWatchEvent        = "CloudWatchEvent"
+	triggerTypeCreatePipeline         = "CreatePipeline"
+	triggerTypePollForSourceChanges   = "PollForSourceChanges"
+	triggerTypePutActionRevision      = "PutActionRevision"
+	triggerTypeStartPipelineExecution = "StartPipelineExecution"
+	triggerTypeWebhook                = "Webhook"
+	triggerTypeWebhookV2              = "WebhookV2"
+)
+
+// executionTrigger is the execution-trigger in a pipeline execution state change event
+type executionTrigger struct {
+	TriggerDetail string `json:"trigger-detail"`
+	TriggerType   string `json:"trigger-type"`
+}
+
+// pullRequestPath will match a pull request number in a url or trigger detail (IE: /pull/42)
+var pullRequestPath = regexp.MustCompile(`/pulls?/(\d+)`)
+
+// pullRequestKeys are the (lowercase) JSON keys that can hold the pull request number
+var pullRequestKeys = []string{"pullrequestid", "pullrequestnumber", "pullrequest"}
+
+// applyExecutionTrigger will use the V2 trigger details (source revisions) to set the head commit
+// and the pull request number on the revisions (pull request and git push triggers)
+func applyExecutionTrigger(executionOutput *codepipeline.GetPipelineExecutionOutput,
+	pipeline codepipelineiface.CodePipelineAPI, revisions []*sourceRevision,
+) error {
+
+	// Only V2 (git configuration) triggers carry the source revisions
+	execution := executionOutput.PipelineExecution
+	if execution.Trigger == nil || aws.StringValue(execution.Trigger.TriggerType) != triggerTypeWebhookV2 {
+		return nil
+	}
+
+	// Get the execution summary (source revisions)
+	summary, err := getExecutionSummary(
+		aws.StringValue(execution.PipelineName), aws.StringValue(execution.PipelineExecutionId), pipeline,
+	)
+	if err != nil {
+		return err
+	} else if summary == nil {
+		return nil
+	}
+
+	// Match the source revisions to the artifact revisions by repository
+	for _, revision := range revisions {
+		sourceRevision := getMatchingSourceRevision(revision, summary.SourceRevisions)
+		if sourceRevision == nil {
+			continue
+		}
+		if commit := aws.StringValue(sourceRevision.RevisionId); len(commit) > 0 {
+			revision.Commit = commit
+		}
+		revision.PullRequest = getPullRequestNumber(
+			aws.StringValue(sourceRevision.RevisionSummary),
+			aws.StringValue(sourceRevision.RevisionUrl),
+			aws.StringValue(execution.Trigger.TriggerDetail),
+		)
+	}
+	return nil
+}
+
+// getExecutionSummary will find the execution summary (ListPipelineExecutions) for an execution
+func getExecutionSummary(pipelineName, executionID string,
+	pipeline codepipelineiface.CodePipelineAPI,
+) (summary *codepipeline.PipelineExecutionSummary, err error) {
+	pages := 0
+	err = pipeline.ListPipelineExecutionsPages(&codepipeline.ListPipelineExecutionsInput{
+		PipelineName: aws.String(pipelineName),
+	}, func(page *codepipeline.ListPipelineExecutionsOutput, _ bool) bool {
+		for _, executionSummary := range page.PipelineExecutionSummaries {
+			if aws.StringValue(executionSummary.PipelineExecutionId) == executionID {
+				summary = executionSummary
+				return false
+			}
+		}
+		pages++
+		return pages < maxExecutionSummaryPages
+	})
+	return
+}
+
+// getMatchingSourceRevision will return the source revision for the same repository as the revision
+func getMatchingSourceRevision(revision *sourceRevision, sourceRevisions []*codepipeline.SourceRevision) *codepipeline.SourceRevision {
+
+	// Single source pipeline
+	if len(sourceRevisions) == 1 {
+		return sourceRevisions[0]
+	}
+
+	// Match the repository of the revision urls
+	owner, repo, err := getGitHubRepository(revision.RevisionURL)
+	if err != nil {
+		return nil
+	}
+	for _, sourceRevision := range sourceRevisions {
+		revisionURL, parseErr := url.Parse(aws.StringValue(sourceRevision.RevisionUrl))
+		if parseErr != nil {
+			continue
+		}
+		if sourceOwner, sourceRepo, repoErr := getGitHubRepository(revisionURL); repoErr == nil &&
+			strings.EqualFold(sourceOwner, owner) && strings.EqualFold(sourceRepo, repo) {
+			return sourceRevision
+		}
+	}
+	return nil
+}
+
+// getPullRequestNumber will return the pull request number from the revision summary (JSON),
+// revision url or the trigger detail (0 if not found)
+func getPullRequestNumber(revisionSummary, revisionURL, triggerDetail string) int {
+
+	// Revision summary (JSON)
+	var summary map[string]interface{}
+	if err := json.Unmarshal([]byte(revisionSummary), &summary); err == nil {
+		for name, value := range summary {
+			for _, key := range pullRequestKeys {
+				if strings.ToLower(name) != key {
+					continue
+				}
+				if number, ok := toPullRequestNumber(value); ok {
+					return number
+				}
+			}
+		}
+	}
+
+	// Revision url or trigger detail
+	for _, value := range []string{revisionURL, triggerDetail} {
+		if matches := pullRequestPath.FindStringSubmatch(value); len(matches) == 2 {
+			if number, err := strconv.Atoi(matches[1]); err == nil {
+				return number
+			}
+		}
+	}
+	return 0
+}
+
+// toPullRequestNumber will convert a JSON value (number or string) to a pull request number
+func toPullRequestNumber(value interface{}) (int, bool) {
+	switch v := value.(type) {
+	case float64:
+		return int(v), v > 0
+	case string:
+		number, err := strconv.Atoi(strings.TrimPrefix(v, "#"))
+		return number, err == nil && number > 0
+	}
+	return 0, false
+}
+
+// getStatusDescription will return the status description (GitHub limits descriptions to 140 characters)
+func getStatusDescription(status string, revision *sourceRevision, trigger *executionTrigger) (description string) {
+	switch status {
+	case "pending":
+		description = "Pipeline execution is in progress"
+	case "success":
+		description = "Pipeline execution succeeded"
+	default:
+		description = "Pipeline execution failed"
+	}
+	if revision != nil && revision.PullRequest > 0 {
+		description = fmt.Sprintf("Pull request #%d: %s", revision.PullRequest, description)
+	}
+	if startedBy := getTriggerDescription(trigger); len(startedBy) > 0 {
+		description += ", " + startedBy
+	}
+	if len(description) > 140 {
+		description = description[:137] + "..."
+	}
+	return
+}
+
+// getTriggerDescription will describe what started the pipeline execution (IE: started by webhook)
+func getTriggerDescription(trigger *executionTrigger) string {
+	if trigger == nil {
+		return ""
+	}
+	switch trigger.TriggerType {
+	case triggerTypeWebhook, triggerTypeWebhookV2:
+		return "started by webhook"
+	case triggerTypeStartPipelineExecution:
+		if user := getTriggerUser(trigger.TriggerDetail); len(user) > 0 {
+			return "started by user " + user
+		}
+		return "started manually"
+	case triggerTypeCloudWatchEvent:
+		return "started by event rule"
+	case triggerTypePollForSourceChanges:
+		return "started by source change"
+	case triggerTypeCreatePipeline:
+		return "started by pipeline creation"
+	case triggerTypePutActionRevision:
+		return "started by action revision"
+	}
+	return ""
+}
+
+// getTriggerUser will return the user (or role session) from the trigger detail (IAM or STS ARN)
+func getTriggerUser(triggerDetail string) string {
+	if !strings.HasPrefix(triggerDetail, "arn:") {
+		return triggerDetail
+	}
+	return triggerDetail[strings.LastIndex(triggerDetail, "/")+1:]
+}
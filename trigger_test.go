@@ -0,0 +1,94 @@
+package main
+
+import (
+	"testing"
+)
+
+// TestApplyExecutionTrigger will test getting the head commit and pull request from a V2 trigger
+func TestApplyExecutionTrigger(t *testing.T) {
+	t.Parallel()
+
+	mockPipeline := &mockCodePipelineClient{}
+
+	// V2 pull request trigger
+	revisions, _, err := getRevisions("v2-pull-request", "12345", mockPipeline, nil)
+	if err != nil {
+		t.Fatal("error occurred", err.Error())
+	} else if len(revisions) != 1 {
+		t.Fatal("expected a single revision", len(revisions))
+	} else if revisions[0].Commit != "f00dbabe61c4db2c2cde8b163b3ad096875c1ce0" {
+		t.Fatal("expected the head commit from the source revision", revisions[0].Commit)
+	} else if revisions[0].PullRequest != 42 {
+		t.Fatal("expected pull request 42", revisions[0].PullRequest)
+	}
+
+	// No trigger details (V1 pipeline)
+	if revisions, _, err = getRevisions("some-pipeline", "12345", mockPipeline, nil); err != nil {
+		t.Fatal("error occurred", err.Error())
+	} else if revisions[0].Commit != "25c0c3e61c4db2c2cde8b163b3ad096875c1ce08" || revisions[0].PullRequest != 0 {
+		t.Fatal("revision was not as expected", revisions[0])
+	}
+}
+
+// TestGetPullRequestNumber will test getPullRequestNumber()
+func TestGetPullRequestNumber(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		revisionSummary string
+		revisionURL     string
+		triggerDetail   string
+		expected        int
+	}{
+		{`{"PullRequestId":"42"}`, "", "", 42},
+		{`{"pullRequestNumber":7}`, "", "", 7},
+		{`{"ProviderType":"GitHub"}`, "https://github.com/mrz1836/repo/pull/12", "", 12},
+		{"Some commit message", "", "https://github.com/mrz1836/repo/pulls/3", 3},
+		{"Some commit message", "https://github.com/mrz1836/repo/commit/abc", "arn:aws:codestar-connections", 0},
+	}
+
+	for _, test := range tests {
+		if number := getPullRequestNumber(test.revisionSummary, test.revisionURL, test.triggerDetail); number != test.expected {
+			t.Errorf("%s Failed: [%s] expected [%d] got [%d]", t.Name(), test.revisionSummary, test.expected, number)
+		}
+	}
+}
+
+// TestGetStatusDescription will test getStatusDescription()
+func TestGetStatusDescription(t *testing.T) {
+	t.Parallel()
+
+	if description := getStatusDescription("success", nil, nil); description != "Pipeline execution succeeded" {
+		t.Fatal("description was not as expected", description)
+	} else if description = getStatusDescription("failure", &sourceRevision{PullRequest: 42}, nil); description != "Pull request #42: Pipeline execution failed" {
+		t.Fatal("description was not as expected", description)
+	} else if description = getStatusDescription("pending", nil, &executionTrigger{
+		TriggerType: triggerTypeWebhook,
+	}); description != "Pipeline execution is in progress, started by webhook" {
+		t.Fatal("description was not as expected", description)
+	}
+}
+
+// TestGetTriggerDescription will test getTriggerDescription()
+func TestGetTriggerDescription(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		trigger             *executionTrigger
+		expectedDescription string
+	}{
+		{nil, ""},
+		{&executionTrigger{TriggerType: triggerTypeWebhookV2, TriggerDetail: "arn:aws:codestar-connections:us-east-1:123456789012:connection/abc"}, "started by webhook"},
+		{&executionTrigger{TriggerType: triggerTypeStartPipelineExecution, TriggerDetail: "arn:aws:iam::123456789012:user/jane"}, "started by user jane"},
+		{&executionTrigger{TriggerType: triggerTypeStartPipelineExecution, TriggerDetail: "arn:aws:sts::123456789012:assumed-role/Admin/jane@example.com"}, "started by user jane@example.com"},
+		{&executionTrigger{TriggerType: triggerTypeStartPipelineExecution}, "started manually"},
+		{&executionTrigger{TriggerType: triggerTypePollForSourceChanges}, "started by source change"},
+		{&executionTrigger{TriggerType: "Unknown"}, ""},
+	}
+
+	for _, test := range tests {
+		if description := getTriggerDescription(test.trigger); description != test.expectedDescription {
+			t.Errorf("%s Failed: expected [%s] got [%s]", t.Name(), test.expectedDescription, description)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/codepipeline"
+	"github.com/aws/aws-sdk-go/service/codepipeline/codepipelineiface"
+)
+
+// Verify defaults
+const (
+	detailTypeVerify = "Verify" // Direct invocation: {"detail-type": "Verify"}
+	verifyFail       = "FAIL"
+	verifyPass       = "PASS"
+	verifySkip       = "SKIP"
+)
+
+// verifyCheck is the result of one check of the verify mode
+type verifyCheck struct {
+	Detail string `json:"detail,omitempty"`
+	Name   string `json:"name"`
+	Result string `json:"result"`
+}
+
+// verifyReport is the report of the verify mode (passed if no check failed)
+type verifyReport struct {
+	Checks []*verifyCheck `json:"checks"`
+	Passed bool           `json:"passed"`
+}
+
+// verifyCredential is a GitHub credential to verify
+type verifyCredential struct {
+	label    string
+	provider credentialProvider
+}
+
+// add will add the result of a check to the report
+func (r *verifyReport) add(name, result, detail string) {
+	r.Checks = append(r.Checks, &verifyCheck{Detail: detail, Name: name, Result: result})
+	if result == verifyFail {
+		r.Passed = false
+	}
+}
+
+// String will return the printable report (one line per check)
+func (r *verifyReport) String() string {
+	var b strings.Builder
+	for _, check := range r.Checks {
+		_, _ = fmt.Fprintf(&b, "[%s] %s", check.Result, check.Name)
+		if len(check.Detail) > 0 {
+			_, _ = fmt.Fprintf(&b, ": %s", check.Detail)
+		}
+		b.WriteString("\n")
+	}
+	result := verifyPass
+	if !r.Passed {
+		result = verifyFail
+	}
+	_, _ = fmt.Fprintf(&b, "verify: %s (%d checks)\n", result, len(r.Checks))
+	return b.String()
+}
+
+// runVerify will run the verify command (verify -pipeline name)
+func runVerify(args []string) error {
+	flags := flag.NewFlagSet("verify", flag.ContinueOnError)
+	pipelineName := flags.String("pipeline", "", "pipeline name, or all (default: RECONCILE_PIPELINES)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	report := verify(context.Background(), *pipelineName, codepipeline.New(awsSession))
+	_, _ = fmt.Fprint(os.Stdout, report.String())
+	if !report.Passed {
+		return fmt.Errorf("verify failed")
+	}
+	return nil
+}
+
+// verify will check the configuration, the GitHub credentials (identity, scopes and rate limit),
+// the KMS decryption and the access to the executions of the pipelines
+func verify(ctx context.Context, pipelineName string, pipeline codepipelineiface.CodePipelineAPI) *verifyReport {
+	report := &verifyReport{Passed: true}
+
+	// Load the configuration (decrypts the default token)
+	if err := getConfiguration(); err != nil {
+		report.add("configuration", verifyFail, err.Error())
+		return report
+	}
+	cfg := getConfig()
+	report.add("configuration", verifyPass, "stage "+cfg.Stage)
+
+	// GitHub credentials (default, owners and routes)
+	var checkedKMS bool
+	for _, credential := range getVerifyCredentials(cfg) {
+		if credential.provider.Name() == credentialProviderKMS {
+			checkedKMS = true
+		}
+		verifyGithubCredential(ctx, report, credential)
+	}
+	if !checkedKMS {
+		report.add("kms decrypt", verifySkip, "no KMS encrypted tokens")
+	}
+
+	// Pipelines
+	if len(pipelineName) > 0 {
+		verifyPipelines(report, []string{pipelineName}, pipeline)
+	} else {
+		verifyPipelines(report, cfg.ReconcilePipelines, pipeline)
+	}
+	return report
+}
+
+// getVerifyCredentials will return the loaded credentials (default, owners and routes)
+func getVerifyCredentials(cfg *loadedConfiguration) (credentials []*verifyCredential) {
+	if cfg.credentials != nil {
+		credentials = append(credentials, &verifyCredential{label: "default", provider: cfg.credentials})
+	}
+	owners := make([]string, 0, len(cfg.owners))
+	for owner := range cfg.owners {
+		owners = append(owners, owner)
+	}
+	sort.Strings(owners)
+	for _, owner := range owners {
+		credentials = append(credentials, &verifyCredential{label: "owner " + owner, provider: cfg.owners[owner]})
+	}
+	for i, route := range cfg.routes {
+		if route.credentials == nil {
+			continue
+		}
+		name := route.Name
+		if len(name) == 0 {
+			name = fmt.Sprintf("%d", i+1)
+		}
+		credentials = append(credentials, &verifyCredential{label: "route " + name, provider: route.credentials})
+	}
+	return
+}
+
+// verifyGithubCredential will check the token of the credential (decrypt or read), its user, scopes and rate limit
+func verifyGithubCredential(ctx context.Context, report *verifyReport, credential *verifyCredential) {
+
+	// Decrypt or read the token
+	name := credential.label + " token (" + credential.provider.Name() + ")"
+	if credential.provider.Name() == credentialProviderKMS {
+		name = credential.label + " kms decrypt"
+	}
+	if _, err := credential.provider.Token(false); err != nil {
+		report.add(name, verifyFail, err.Error())
+		return
+	}
+	report.add(name, verifyPass, "")
+
+	// Nothing is sent to GitHub on a dry-run
+	if getConfig().DryRun {
+		report.add(credential.label+" github", verifySkip, "dry-run")
+		return
+	}
+
+	// Identity and scopes
+	ctx = withCredentials(ctx, credential.provider)
+	user, err := getTokenUser(ctx)
+	if err != nil {
+		report.add(credential.label+" github user", verifyFail, err.Error())
+		return
+	}
+	report.add(credential.label+" github user", verifyPass, user.Login)
+	if missing := getMissingScopes(user); !user.ClassicToken {
+		report.add(credential.label+" github scopes", verifySkip, "fine-grained or app token (no scopes header)")
+	} else if len(missing) > 0 {
+		report.add(credential.label+" github scopes", verifyFail,
+			fmt.Sprintf("missing: %s (scopes: %s)", strings.Join(missing, ", "), strings.Join(user.Scopes, ", ")))
+	} else {
+		report.add(credential.label+" github scopes", verifyPass, strings.Join(user.Scopes, ", "))
+	}
+
+	// Rate limit
+	var rateLimit *githubRateLimit
+	if rateLimit, err = getRateLimit(ctx); err != nil {
+		report.add(credential.label+" github rate limit", verifyFail, err.Error())
+		return
+	}
+	core := rateLimit.Resources.Core
+	detail := fmt.Sprintf("%d/%d remaining", core.Remaining, core.Limit)
+	if core.Remaining == 0 {
+		report.add(credential.label+" github rate limit", verifyFail,
+			detail+", resets at "+time.Unix(core.Reset, 0).UTC().Format(time.RFC3339))
+		return
+	}
+	report.add(credential.label+" github rate limit", verifyPass, detail)
+}
+
+// getMissingScopes will return the scopes a classic token needs (statuses, and deployments if enabled)
+func getMissingScopes(user *githubTokenUser) (missing []string) {
+	scopes := make(map[string]bool, len(user.Scopes))
+	for _, scope := range user.Scopes {
+		scopes[scope] = true
+	}
+	if !scopes["repo"] && !scopes["repo:status"] {
+		missing = append(missing, "repo:status")
+	}
+	if isEventKindEnabled(eventKindDeployment, "") && !scopes["repo"] && !scopes["repo_deployment"] {
+		missing = append(missing, "repo_deployment")
+	}
+	return
+}
+
+// verifyPipelines will check the role can list and get the latest execution of each pipeline
+func verifyPipelines(report *verifyReport, names []string, pipeline codepipelineiface.CodePipelineAPI) {
+	var pipelineNames []string
+	for _, name := range names {
+		resolved, err := getPipelineNames(strings.TrimSpace(name), pipeline)
+		if err != nil {
+			report.add("pipeline "+name, verifyFail, err.Error())
+			continue
+		}
+		pipelineNames = append(pipelineNames, resolved...)
+	}
+
+	for _, pipelineName := range pipelineNames {
+		executions, err := getRecentExecutions(pipelineName, 1, 0, pipeline)
+		if err != nil {
+			report.add("pipeline "+pipelineName, verifyFail, err.Error())
+			continue
+		} else if len(executions) == 0 {
+			report.add("pipeline "+pipelineName, verifySkip, "no executions")
+			continue
+		}
+		executionID := aws.StringValue(executions[0].PipelineExecutionId)
+		if _, err = getExecutionOutput(pipelineName, executionID, pipeline); err != nil {
+			report.add("pipeline "+pipelineName, verifyFail, err.Error())
+			continue
+		}
+		report.add("pipeline "+pipelineName, verifyPass, "execution "+executionID)
+	}
+}
+
+// logVerifyReport will log the report (one line per check)
+func logVerifyReport(report *verifyReport) {
+	for _, line := range strings.Split(strings.TrimSpace(report.String()), "\n") {
+		log.Print(line)
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// newVerifyServer will return a GitHub server for the verify mode (user, scopes and rate limit)
+func newVerifyServer(t *testing.T, scopes string, remaining int) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token 1234567" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/user":
+			if len(scopes) > 0 {
+				w.Header().Set("X-OAuth-Scopes", scopes)
+			}
+			_, _ = w.Write([]byte(`{"login":"status-bot"}`))
+		case "/rate_limit":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"resources": map[string]interface{}{
+					"core": map[string]interface{}{"limit": 5000, "remaining": remaining, "reset": 1700000000},
+				},
+			})
+		default:
+			t.Error("unexpected request", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// getVerifyCheck will return the check of the report by name (nil if not found)
+func getVerifyCheck(report *verifyReport, name string) *verifyCheck {
+	for _, check := range report.Checks {
+		if check.Name == name {
+			return check
+		}
+	}
+	return nil
+}
+
+// TestVerify will test verify() with the scopes, rate limit and pipelines
+func TestVerify(t *testing.T) {
+
+	var tests = []struct {
+		name           string
+		scopes         string
+		remaining      int
+		pipelineName   string
+		expectedPassed bool
+		expectedCheck  string
+		expectedResult string
+	}{
+		{"repo scope", "repo, read:org", 4999, "backfill", true, "default github scopes", verifyPass},
+		{"status and deployment scopes", "repo:status, repo_deployment", 4999, "backfill", true, "default github scopes", verifyPass},
+		{"missing deployment scope", "repo:status", 4999, "backfill", false, "default github scopes", verifyFail},
+		{"missing scopes", "read:org", 4999, "backfill", false, "default github scopes", verifyFail},
+		{"fine-grained token", "", 4999, "backfill", true, "default github scopes", verifySkip},
+		{"rate limited", "repo", 0, "backfill", false, "default github rate limit", verifyFail},
+		{"pipeline", "repo", 4999, "backfill", true, "pipeline backfill", verifyPass},
+		{"all pipelines", "repo", 4999, pipelineAll, true, "pipeline v2-pull-request", verifyPass},
+		{"missing pipeline name", "repo", 4999, " ", false, "pipeline ", verifyFail},
+	}
+
+	for _, test := range tests {
+		setHTTPEnvironment(t, httpAuthSecret)
+		_ = os.Setenv("GITHUB_API_URL", newVerifyServer(t, test.scopes, test.remaining).URL)
+
+		report := verify(context.Background(), test.pipelineName, &mockCodePipelineClient{})
+		if check := getVerifyCheck(report, test.expectedCheck); check == nil {
+			t.Errorf("%s Failed: [%s] missing check [%s]", t.Name(), test.name, test.expectedCheck)
+		} else if check.Result != test.expectedResult {
+			t.Errorf("%s Failed: [%s] expected [%s] got [%s] %s", t.Name(), test.name, test.expectedResult, check.Result, check.Detail)
+		} else if report.Passed != test.expectedPassed {
+			t.Errorf("%s Failed: [%s] expected passed [%t]\n%s", t.Name(), test.name, test.expectedPassed, report.String())
+		}
+	}
+	_ = os.Unsetenv("GITHUB_API_URL")
+}
+
+// TestVerifyCredentials will test verify() with an invalid token, a KMS credential and the configuration
+func TestVerifyCredentials(t *testing.T) {
+	setHTTPEnvironment(t, httpAuthSecret)
+	server := newVerifyServer(t, "repo", 4999)
+	_ = os.Setenv("GITHUB_API_URL", server.URL)
+	defer func() {
+		_ = os.Unsetenv("GITHUB_API_URL")
+	}()
+
+	// Unauthorized token
+	if err := getConfiguration(); err != nil {
+		t.Fatal("error occurred", err.Error())
+	}
+	setConfig(t, func(c *loadedConfiguration) {
+		c.credentials = &envCredentials{token: "invalid"}
+	})
+	report := verify(context.Background(), "backfill", &mockCodePipelineClient{})
+	if check := getVerifyCheck(report, "default github user"); check == nil || check.Result != verifyFail {
+		t.Fatal("expected the user check to fail", check)
+	} else if report.Passed {
+		t.Fatal("expected the report to fail")
+	} else if check = getVerifyCheck(report, "kms decrypt"); check == nil || check.Result != verifySkip {
+		t.Fatal("expected the kms check to be skipped", check)
+	}
+
+	// KMS decrypt (the 401 reloads the configuration)
+	if err := getConfiguration(); err != nil {
+		t.Fatal("error occurred", err.Error())
+	}
+	setConfig(t, func(c *loadedConfiguration) {
+		c.owners = map[string]credentialProvider{
+			"decrypted": &kmsCredentials{encryptedToken: "ZW5jcnlwdGVk", kmsSvc: &mockKmsClient{}},
+			"invalid":   &kmsCredentials{kmsSvc: &mockKmsClient{}},
+		}
+	})
+	report = verify(context.Background(), "backfill", &mockCodePipelineClient{})
+	if check := getVerifyCheck(report, "owner decrypted kms decrypt"); check == nil || check.Result != verifyPass {
+		t.Fatal("expected the kms check to pass", check)
+	} else if check = getVerifyCheck(report, "owner invalid kms decrypt"); check == nil || check.Result != verifyFail {
+		t.Fatal("expected the kms check to fail", check)
+	}
+
+	// Invalid configuration
+	resetConfiguration()
+	_ = os.Unsetenv("APPLICATION_STAGE_NAME")
+	report = verify(context.Background(), "backfill", &mockCodePipelineClient{})
+	if check := getVerifyCheck(report, "configuration"); check == nil || check.Result != verifyFail || report.Passed {
+		t.Fatal("expected the configuration check to fail", check)
+	} else if !strings.Contains(report.String(), "verify: FAIL") {
+		t.Fatal("report was not as expected", report.String())
+	}
+}
+
+// TestHandleRequestVerify will test the verify event of handleRequest()
+func TestHandleRequestVerify(t *testing.T) {
+	setHTTPEnvironment(t, httpAuthSecret)
+	_ = os.Setenv("DRY_RUN", "true")
+	_ = os.Setenv("RECONCILE_PIPELINES", "")
+	defer func() {
+		_ = os.Unsetenv("DRY_RUN")
+		_ = os.Unsetenv("RECONCILE_PIPELINES")
+	}()
+
+	// Nothing is sent to GitHub on a dry-run (no pipelines to check)
+	result, err := handleRequest(context.Background(), []byte(`{"detail-type":"Verify"}`))
+	if err != nil {
+		t.Fatal("error occurred", err.Error())
+	}
+	report, ok := result.(*verifyReport)
+	if !ok {
+		t.Fatal("expected the report to be returned", result)
+	} else if check := getVerifyCheck(report, "default token (env)"); check == nil || check.Result != verifyPass {
+		t.Fatal("expected the token check to pass", check)
+	} else if check = getVerifyCheck(report, "default github"); check == nil || check.Result != verifySkip {
+		t.Fatal("expected the github checks to be skipped", check)
+	}
+}